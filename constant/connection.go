@@ -4,6 +4,12 @@ const (
 	ConnStartSignal uint8 = iota
 	ConnStopSignal
 	ConnNotActiveSignal
+	// 连接超过读空闲时间未收到数据
+	ConnReaderIdleSignal
+	// 连接超过写空闲时间未发送数据
+	ConnWriterIdleSignal
+	// 连接超过读写空闲时间未收发数据
+	ConnAllIdleSignal
 )
 
 const (
@@ -16,3 +22,70 @@ const (
 	// 连接健康状态巡检时如果为检测状态则设置为不活跃状态
 	ConnNotActiveState
 )
+
+const (
+	// 任务队列已满时暂停读取连接的数据，待请求进入队列后恢复读取
+	OverloadPause = iota
+	// 任务队列已满时丢弃请求，并回复过载消息
+	OverloadDrop
+	// 任务队列已满时断开连接
+	OverloadDisconnect
+	// 过载策略的数量
+	OverloadPolicies
+)
+
+const (
+	// 在线连接数达到上限
+	RejectMaxConns = iota
+	// 连接地址在黑名单中或不在白名单中
+	RejectDenied
+	// 单个IP的连接数达到上限
+	RejectIPLimit
+	// 网段的连接数达到上限
+	RejectCIDRLimit
+	// 接收连接的速率超过限制
+	RejectAcceptRate
+	// 接收连接的观察函数拒绝了连接
+	RejectObserver
+	// 同时读取PROXY协议头部的连接数达到上限
+	RejectProxyHeaderReads
+	// 拒绝原因的数量
+	RejectReasons
+)
+
+const (
+	// 连接不活跃时只通知钩子函数，不关闭连接
+	IdleNotify = iota
+	// 连接不活跃时通知钩子函数并关闭连接
+	IdleClose
+	// 连接不活跃时先发送探测消息，下一个巡检周期仍未收到数据时关闭连接
+	IdleProbe
+)
+
+const (
+	// 读空闲，超过读空闲时间未收到数据
+	ReaderIdle = iota
+	// 写空闲，超过写空闲时间未发送数据
+	WriterIdle
+	// 读写空闲，超过读写空闲时间未收发数据
+	AllIdle
+)
+
+const (
+	// 客户端关闭连接
+	CloseByPeer = iota
+	// 读取或解析连接的数据出错
+	CloseByError
+	// 连接不活跃被驱逐
+	CloseByIdle
+	// 连接超时未通过握手鉴权
+	CloseByHandshake
+	// 服务端主动关闭连接
+	CloseByServer
+	// 引擎停止
+	CloseByShutdown
+	// 通过管理接口踢出连接
+	CloseByKick
+	// 关闭原因的数量
+	CloseReasons
+)
@@ -0,0 +1,12 @@
+package constant
+
+const (
+	// 收到客户端的消息
+	ObserveMessageIn = iota
+	// 发送消息给客户端
+	ObserveMessageOut
+	// 连接读写出错
+	ObserveConnError
+	// 观察事件的数量
+	ObserveEvents
+)
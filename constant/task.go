@@ -0,0 +1,37 @@
+package constant
+
+const (
+	// 高优先级，适用于心跳、登录等控制类消息
+	TaskPriorityHigh = iota
+	// 普通优先级，路由的默认优先级
+	TaskPriorityNormal
+	// 低优先级，适用于批量同步等大流量消息
+	TaskPriorityLow
+	// 优先级的数量
+	TaskPriorityClasses
+)
+
+const (
+	// 加权调度，按照各优先级的权重轮流消费队列，低优先级的任务不会饿死
+	WeightedPrioritySchedule = iota
+	// 严格优先级调度，高优先级队列为空时才消费低优先级队列
+	StrictPrioritySchedule
+)
+
+const (
+	// 连接级别的状态，每个连接持有一份，连接关闭时释放
+	StateScopeConn = iota
+	// 路由级别的状态，所有请求共享一份
+	StateScopeRoute
+	// 请求级别的状态，每个请求持有一份，请求处理结束时释放
+	StateScopeRequest
+)
+
+const (
+	// 处理函数执行时间超过阈值的慢请求
+	SlowHandler = iota
+	// 在任务队列中等待时间超过阈值的慢请求
+	SlowQueueWait
+	// 慢请求类型的数量
+	SlowKinds
+)
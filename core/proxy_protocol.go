@@ -0,0 +1,175 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// PROXY协议v1头部的最大长度，包含结尾的CRLF
+	proxyV1MaxLen = 107
+	// PROXY协议v2头部的固定长度，包含签名、版本命令、地址族与地址长度
+	proxyV2HeaderLen = 16
+)
+
+// PROXY协议v2头部的签名
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// ProxyHeader PROXY协议头部，记录代理转发前客户端的源地址与目的地址
+type ProxyHeader struct {
+	// 协议版本，1或2
+	Version int
+	// 是否携带了转发的地址，LOCAL命令、UNKNOWN协议或不支持的地址族时为false，此时应使用连接的对端地址
+	Proxied     bool
+	Source      net.Addr
+	Destination net.Addr
+}
+
+// ReadProxyHeader 从连接中读取PROXY协议v1或v2头部，只读取头部本身的字节，不会多读后续的业务数据
+func ReadProxyHeader(r io.Reader) (*ProxyHeader, error) {
+	// v1头部的最小长度大于12，读取12字节不会越过头部
+	prefix := make([]byte, len(proxyV2Signature))
+	_, err := io.ReadFull(r, prefix)
+	if err != nil {
+		return nil, errors.WithMessage(err, "read proxy header failed")
+	}
+
+	if bytes.Equal(prefix, proxyV2Signature) {
+		return readProxyV2(r)
+	}
+
+	if bytes.HasPrefix(prefix, []byte("PROXY ")) {
+		return readProxyV1(r, prefix)
+	}
+
+	return nil, errors.New("invalid proxy protocol signature")
+}
+
+// readProxyV1 读取文本格式的v1头部，格式为 PROXY TCP4 源地址 目的地址 源端口 目的端口\r\n
+func readProxyV1(r io.Reader, prefix []byte) (*ProxyHeader, error) {
+	line := prefix
+	b := make([]byte, 1)
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= proxyV1MaxLen {
+			return nil, errors.New("proxy protocol v1 header too long")
+		}
+
+		_, err := io.ReadFull(r, b)
+		if err != nil {
+			return nil, errors.WithMessage(err, "read proxy protocol v1 header failed")
+		}
+
+		line = append(line, b[0])
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) < 2 {
+		return nil, errors.Errorf("invalid proxy protocol v1 header: %q", line)
+	}
+
+	header := &ProxyHeader{Version: 1}
+	if fields[1] == "UNKNOWN" {
+		return header, nil
+	}
+
+	if (fields[1] != "TCP4" && fields[1] != "TCP6") || len(fields) != 6 {
+		return nil, errors.Errorf("invalid proxy protocol v1 header: %q", line)
+	}
+
+	src, err := parseProxyV1Addr(fields[2], fields[4])
+	if err != nil {
+		return nil, err
+	}
+
+	dst, err := parseProxyV1Addr(fields[3], fields[5])
+	if err != nil {
+		return nil, err
+	}
+
+	header.Proxied = true
+	header.Source = src
+	header.Destination = dst
+
+	return header, nil
+}
+
+// parseProxyV1Addr 解析v1头部中的地址与端口
+func parseProxyV1Addr(host string, port string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, errors.Errorf("invalid proxy protocol v1 address: %s", host)
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "invalid proxy protocol v1 port: %s", port)
+	}
+
+	return &net.TCPAddr{IP: ip, Port: int(p)}, nil
+}
+
+// readProxyV2 读取二进制格式的v2头部，签名之后依次为版本命令、地址族与协议、地址长度和地址
+func readProxyV2(r io.Reader) (*ProxyHeader, error) {
+	fixed := make([]byte, proxyV2HeaderLen-len(proxyV2Signature))
+	_, err := io.ReadFull(r, fixed)
+	if err != nil {
+		return nil, errors.WithMessage(err, "read proxy protocol v2 header failed")
+	}
+
+	if fixed[0]>>4 != 2 {
+		return nil, errors.Errorf("invalid proxy protocol v2 version: %d", fixed[0]>>4)
+	}
+
+	command := fixed[0] & 0x0F
+	if command > 1 {
+		return nil, errors.Errorf("invalid proxy protocol v2 command: %d", command)
+	}
+
+	family := fixed[1] >> 4
+	addrs := make([]byte, binary.BigEndian.Uint16(fixed[2:4]))
+	_, err = io.ReadFull(r, addrs)
+	if err != nil {
+		return nil, errors.WithMessage(err, "read proxy protocol v2 addresses failed")
+	}
+
+	header := &ProxyHeader{Version: 2}
+
+	// LOCAL命令为代理自身发起的连接，例如健康检查
+	if command == 0 {
+		return header, nil
+	}
+
+	var ipLen int
+	switch family {
+	case 0x1:
+		ipLen = net.IPv4len
+	case 0x2:
+		ipLen = net.IPv6len
+	default:
+		// 未指定或Unix地址族，忽略转发的地址
+		return header, nil
+	}
+
+	if len(addrs) < 2*ipLen+4 {
+		return nil, errors.Errorf("proxy protocol v2 addresses too short: %d", len(addrs))
+	}
+
+	// TLV扩展字段位于地址之后，已随地址一并读取并忽略
+	header.Proxied = true
+	header.Source = &net.TCPAddr{
+		IP:   net.IP(bytes.Clone(addrs[:ipLen])),
+		Port: int(binary.BigEndian.Uint16(addrs[2*ipLen:])),
+	}
+	header.Destination = &net.TCPAddr{
+		IP:   net.IP(bytes.Clone(addrs[ipLen : 2*ipLen])),
+		Port: int(binary.BigEndian.Uint16(addrs[2*ipLen+2:])),
+	}
+
+	return header, nil
+}
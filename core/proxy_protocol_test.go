@@ -0,0 +1,169 @@
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+)
+
+// proxyV2 构造v2头部，command为0时为LOCAL命令，family为地址族
+func proxyV2(command byte, family byte, addrs []byte) []byte {
+	header := append([]byte{}, proxyV2Signature...)
+	header = append(header, 0x20|command, family<<4|0x1)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(addrs)))
+	return append(header, addrs...)
+}
+
+// proxyV2Addrs 构造v2头部的源地址、目的地址与端口
+func proxyV2Addrs(src, dst net.IP, srcPort, dstPort uint16) []byte {
+	addrs := append(append([]byte{}, src...), dst...)
+	addrs = binary.BigEndian.AppendUint16(addrs, srcPort)
+	return binary.BigEndian.AppendUint16(addrs, dstPort)
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	const payload = "payload"
+
+	tests := []struct {
+		name    string
+		input   []byte
+		want    *ProxyHeader
+		wantErr string
+	}{
+		{
+			name:  "v1 tcp4",
+			input: []byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n"),
+			want: &ProxyHeader{Version: 1, Proxied: true,
+				Source:      &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 56324},
+				Destination: &net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 443}},
+		},
+		{
+			name:  "v1 tcp6",
+			input: []byte("PROXY TCP6 2001:db8::1 2001:db8::2 8080 443\r\n"),
+			want: &ProxyHeader{Version: 1, Proxied: true,
+				Source:      &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 8080},
+				Destination: &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 443}},
+		},
+		{
+			name:  "v1 unknown",
+			input: []byte("PROXY UNKNOWN\r\n"),
+			want:  &ProxyHeader{Version: 1},
+		},
+		{
+			name:  "v2 proxy ipv4",
+			input: proxyV2(1, 0x1, proxyV2Addrs(net.IPv4(192, 0, 2, 1).To4(), net.IPv4(198, 51, 100, 1).To4(), 56324, 443)),
+			want: &ProxyHeader{Version: 2, Proxied: true,
+				Source:      &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 56324},
+				Destination: &net.TCPAddr{IP: net.IPv4(198, 51, 100, 1), Port: 443}},
+		},
+		{
+			name:  "v2 proxy ipv6 with tlv",
+			input: proxyV2(1, 0x2, append(proxyV2Addrs(net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"), 8080, 443), 0x04, 0x00, 0x01, 0xff)),
+			want: &ProxyHeader{Version: 2, Proxied: true,
+				Source:      &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 8080},
+				Destination: &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 443}},
+		},
+		{
+			name:  "v2 local",
+			input: proxyV2(0, 0x1, proxyV2Addrs(net.IPv4(192, 0, 2, 1).To4(), net.IPv4(198, 51, 100, 1).To4(), 1, 2)),
+			want:  &ProxyHeader{Version: 2},
+		},
+		{
+			name:  "v2 unspecified family",
+			input: proxyV2(1, 0x0, nil),
+			want:  &ProxyHeader{Version: 2},
+		},
+		{
+			name:    "empty",
+			input:   nil,
+			wantErr: "read proxy header failed",
+		},
+		{
+			name:    "v1 truncated",
+			input:   []byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324"),
+			wantErr: "read proxy protocol v1 header failed",
+		},
+		{
+			name:    "v1 oversized",
+			input:   []byte("PROXY TCP4 " + strings.Repeat("1", proxyV1MaxLen) + "\r\n"),
+			wantErr: "proxy protocol v1 header too long",
+		},
+		{
+			name:    "v1 invalid address",
+			input:   []byte("PROXY TCP4 192.0.2.x 198.51.100.1 56324 443\r\n"),
+			wantErr: "invalid proxy protocol v1 address",
+		},
+		{
+			name:    "v1 invalid port",
+			input:   []byte("PROXY TCP4 192.0.2.1 198.51.100.1 65536 443\r\n"),
+			wantErr: "invalid proxy protocol v1 port",
+		},
+		{
+			name:    "v2 truncated fixed header",
+			input:   proxyV2(1, 0x1, nil)[:14],
+			wantErr: "read proxy protocol v2 header failed",
+		},
+		{
+			name:    "v2 truncated addresses",
+			input:   proxyV2(1, 0x1, proxyV2Addrs(net.IPv4(192, 0, 2, 1).To4(), net.IPv4(198, 51, 100, 1).To4(), 1, 2))[:20],
+			wantErr: "read proxy protocol v2 addresses failed",
+		},
+		{
+			name:    "v2 addresses too short",
+			input:   proxyV2(1, 0x1, []byte{192, 0, 2, 1}),
+			wantErr: "proxy protocol v2 addresses too short",
+		},
+		{
+			name:    "v2 invalid version",
+			input:   append(append([]byte{}, proxyV2Signature...), 0x11, 0x11, 0, 0),
+			wantErr: "invalid proxy protocol v2 version",
+		},
+		{
+			name:    "v2 invalid command",
+			input:   proxyV2(2, 0x1, nil),
+			wantErr: "invalid proxy protocol v2 command",
+		},
+		{
+			name:    "invalid signature",
+			input:   []byte("GET / HTTP/1.1\r\n\r\n"),
+			wantErr: "invalid proxy protocol signature",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := tt.input
+			if tt.wantErr == "" {
+				input = append(input, payload...)
+			}
+			r := bytes.NewReader(input)
+
+			header, err := ReadProxyHeader(r)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if header.Version != tt.want.Version || header.Proxied != tt.want.Proxied {
+				t.Fatalf("header %+v, want %+v", header, tt.want)
+			}
+			if tt.want.Proxied && (header.Source.String() != tt.want.Source.String() || header.Destination.String() != tt.want.Destination.String()) {
+				t.Fatalf("addresses %s -> %s, want %s -> %s", header.Source, header.Destination, tt.want.Source, tt.want.Destination)
+			}
+
+			// 只读取头部本身的字节，后续的业务数据保留在连接中
+			rest := make([]byte, r.Len())
+			r.Read(rest)
+			if string(rest) != payload {
+				t.Fatalf("remaining %q, want %q", rest, payload)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package core
+
+import (
+	"container/list"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Timer 时间轮中的定时器
+type Timer struct {
+	wheel *TimingWheel
+	// 到期时的刻度
+	expiration int64
+	// 周期定时器的周期刻度数，为0时为一次性定时器
+	period int64
+	fn     func()
+
+	// 定时器所在的槽位，由时间轮的锁保护
+	slot    *list.List
+	element *list.Element
+	stopped bool
+}
+
+// Stop 停止定时器，定时器尚未触发或为周期定时器时返回true
+func (t *Timer) Stop() bool {
+	t.wheel.lock.Lock()
+	defer t.wheel.lock.Unlock()
+
+	if t.stopped {
+		return false
+	}
+
+	t.stopped = true
+
+	if t.slot == nil {
+		return false
+	}
+
+	t.slot.Remove(t.element)
+	t.slot, t.element = nil, nil
+
+	return true
+}
+
+// isStopped 定时器是否已被停止
+func (t *Timer) isStopped() bool {
+	t.wheel.lock.Lock()
+	defer t.wheel.lock.Unlock()
+
+	return t.stopped
+}
+
+// TimingWheel 分层时间轮，添加与停止定时器的时间复杂度为O(1)，每个刻度只处理到期槽位中的定时器
+// 第i层时间轮每个槽位的跨度为tick*wheelSize^i，高层的定时器在所在槽位开始时降级到低层，超出最高层跨度时自动增加一层
+type TimingWheel struct {
+	tick      time.Duration
+	wheelSize int64
+
+	// levels[i][j] 第i层时间轮第j个槽位中的定时器
+	levels [][]*list.List
+	// 已经推进的刻度数
+	current int64
+	start   time.Time
+	lock    sync.Mutex
+
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	// 回调panic时调用的函数，为空时输出到标准日志
+	panicHandler func(r any, stack []byte)
+}
+
+// NewTimingWheel 创建时间轮，tick为刻度的精度，wheelSize为每层时间轮的槽位数量
+func NewTimingWheel(tick time.Duration, wheelSize int) *TimingWheel {
+	if tick <= 0 {
+		tick = time.Millisecond
+	}
+	if wheelSize < 2 {
+		wheelSize = 2
+	}
+
+	w := &TimingWheel{
+		tick:      tick,
+		wheelSize: int64(wheelSize),
+		start:     time.Now(),
+		stop:      make(chan struct{}),
+	}
+	w.addLevel()
+
+	return w
+}
+
+// Start 启动时间轮，按照刻度推进时间并触发到期的定时器
+func (w *TimingWheel) Start() {
+	go func() {
+		ticker := time.NewTicker(w.tick)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				w.advance(int64(now.Sub(w.start) / w.tick))
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止时间轮，尚未触发的定时器不再触发
+func (w *TimingWheel) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+}
+
+// OnPanic 设置回调panic时调用的函数，需要在启动前设置
+func (w *TimingWheel) OnPanic(fn func(r any, stack []byte)) {
+	w.panicHandler = fn
+}
+
+// AfterFunc 在d之后触发一次fn，fn在时间轮的协程中执行，不能阻塞
+func (w *TimingWheel) AfterFunc(d time.Duration, fn func()) *Timer {
+	return w.schedule(d, 0, fn)
+}
+
+// Every 每隔d触发一次fn，直到定时器被停止，fn在时间轮的协程中执行，不能阻塞
+func (w *TimingWheel) Every(d time.Duration, fn func()) *Timer {
+	return w.schedule(d, max(w.ticks(d), 1), fn)
+}
+
+// schedule 创建并添加定时器
+func (w *TimingWheel) schedule(d time.Duration, period int64, fn func()) *Timer {
+	t := &Timer{wheel: w, period: period, fn: fn}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	// 按照实际经过的时间计算到期刻度，保证触发时间不早于d，且至少在下一个刻度触发
+	t.expiration = max(w.ticks(time.Since(w.start)+d), w.current+1)
+	w.add(t)
+
+	return t
+}
+
+// ticks 时长对应的刻度数，向上取整
+func (w *TimingWheel) ticks(d time.Duration) int64 {
+	return int64((d + w.tick - 1) / w.tick)
+}
+
+// addLevel 增加一层时间轮
+func (w *TimingWheel) addLevel() {
+	slots := make([]*list.List, w.wheelSize)
+	for i := range slots {
+		slots[i] = list.New()
+	}
+
+	w.levels = append(w.levels, slots)
+}
+
+// add 按照到期刻度与当前刻度的差值将定时器放入对应层的槽位，调用方持有锁
+func (w *TimingWheel) add(t *Timer) {
+	delta := t.expiration - w.current
+	level, span := 0, int64(1)
+	for delta >= span*w.wheelSize {
+		level++
+		span *= w.wheelSize
+
+		if level == len(w.levels) {
+			w.addLevel()
+		}
+	}
+
+	t.slot = w.levels[level][(t.expiration/span)%w.wheelSize]
+	t.element = t.slot.PushBack(t)
+}
+
+// advance 推进到目标刻度，依次降级高层槽位中的定时器并触发到期的定时器
+func (w *TimingWheel) advance(target int64) {
+	for {
+		w.lock.Lock()
+		if w.current >= target {
+			w.lock.Unlock()
+			return
+		}
+
+		w.current++
+
+		// 从高层到低层降级在当前刻度开始的槽位
+		span := int64(1)
+		for level := 1; level < len(w.levels); level++ {
+			span *= w.wheelSize
+		}
+		for level := len(w.levels) - 1; level > 0; level-- {
+			if w.current%span == 0 {
+				w.cascade(w.levels[level][(w.current/span)%w.wheelSize])
+			}
+			span /= w.wheelSize
+		}
+
+		expired := w.expire(w.levels[0][w.current%w.wheelSize])
+		w.lock.Unlock()
+
+		// 在锁外执行回调，回调中可以添加或停止定时器
+		for _, t := range expired {
+			if !t.isStopped() {
+				w.run(t)
+			}
+		}
+	}
+}
+
+// run 执行定时器的回调，回调panic时恢复，避免时间轮的协程退出后所有定时器都不再触发
+func (w *TimingWheel) run(t *Timer) {
+	defer func() {
+		if r := recover(); r != nil {
+			if w.panicHandler != nil {
+				w.panicHandler(r, debug.Stack())
+				return
+			}
+
+			log.Printf("timing wheel callback panic: %v\n%s\n", r, debug.Stack())
+		}
+	}()
+
+	t.fn()
+}
+
+// cascade 将槽位中的定时器重新放入时间轮，调用方持有锁
+func (w *TimingWheel) cascade(slot *list.List) {
+	for e := slot.Front(); e != nil; {
+		next := e.Next()
+		t := slot.Remove(e).(*Timer)
+		w.add(t)
+		e = next
+	}
+}
+
+// expire 取出槽位中到期的定时器，周期定时器重新放入时间轮，调用方持有锁
+func (w *TimingWheel) expire(slot *list.List) []*Timer {
+	var expired []*Timer
+	for e := slot.Front(); e != nil; {
+		next := e.Next()
+		t := slot.Remove(e).(*Timer)
+		t.slot, t.element = nil, nil
+
+		if t.expiration > w.current {
+			w.add(t)
+		} else {
+			expired = append(expired, t)
+
+			if t.period > 0 {
+				t.expiration = w.current + t.period
+				w.add(t)
+			}
+		}
+
+		e = next
+	}
+
+	return expired
+}
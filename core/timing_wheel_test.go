@@ -0,0 +1,128 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+// testTick 测试用的刻度，远大于测试执行的时间，到期刻度只由定时的时长决定，测试通过advance手动推进时间轮
+const testTick = time.Hour
+
+func TestTimingWheelCascade(t *testing.T) {
+	tests := []struct {
+		name string
+		d    time.Duration
+		// 定时器添加后时间轮的最少层数
+		levels int
+	}{
+		{name: "first level", d: 2 * testTick, levels: 1},
+		{name: "second level", d: 5 * testTick, levels: 2},
+		{name: "overflow level", d: 50 * testTick, levels: 3},
+		{name: "overflow level across slot boundary", d: 64 * testTick, levels: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewTimingWheel(testTick, 4)
+
+			fired := 0
+			timer := w.AfterFunc(tt.d, func() {
+				fired++
+			})
+
+			if len(w.levels) < tt.levels {
+				t.Fatalf("levels %d, want at least %d", len(w.levels), tt.levels)
+			}
+
+			w.advance(timer.expiration - 1)
+			if fired != 0 {
+				t.Fatalf("fired before expiration %d", timer.expiration)
+			}
+
+			w.advance(timer.expiration)
+			if fired != 1 {
+				t.Fatalf("fired %d times at expiration, want 1", fired)
+			}
+
+			w.advance(timer.expiration + 100)
+			if fired != 1 {
+				t.Fatalf("fired %d times after expiration, want 1", fired)
+			}
+		})
+	}
+}
+
+func TestTimingWheelStopBeforeFire(t *testing.T) {
+	w := NewTimingWheel(testTick, 4)
+
+	fired := false
+	timer := w.AfterFunc(20*testTick, func() {
+		fired = true
+	})
+
+	// 定时器在高层时间轮中，降级前停止
+	w.advance(10)
+	if !timer.Stop() {
+		t.Fatal("stop pending timer returned false")
+	}
+	if timer.Stop() {
+		t.Fatal("stop stopped timer returned true")
+	}
+
+	w.advance(100)
+	if fired {
+		t.Fatal("stopped timer fired")
+	}
+}
+
+func TestTimingWheelEvery(t *testing.T) {
+	w := NewTimingWheel(testTick, 4)
+
+	var fires []int64
+	var timer *Timer
+	timer = w.Every(3*testTick, func() {
+		fires = append(fires, w.current)
+		if len(fires) == 10 {
+			// 在回调中停止周期定时器
+			timer.Stop()
+		}
+	})
+
+	first := timer.expiration
+	w.advance(first + 100)
+
+	if len(fires) != 10 {
+		t.Fatalf("fired %d times, want 10", len(fires))
+	}
+	for i, tick := range fires {
+		if want := first + int64(i)*3; tick != want {
+			t.Fatalf("fire %d at tick %d, want %d", i, tick, want)
+		}
+	}
+}
+
+func TestTimingWheelPanic(t *testing.T) {
+	w := NewTimingWheel(testTick, 4)
+
+	var recovered []any
+	w.OnPanic(func(r any, stack []byte) {
+		recovered = append(recovered, r)
+	})
+
+	fired := 0
+	w.AfterFunc(testTick, func() {
+		panic("boom")
+	})
+	every := w.Every(testTick, func() {
+		fired++
+	})
+
+	w.advance(every.expiration + 2)
+
+	if len(recovered) != 1 || recovered[0] != "boom" {
+		t.Fatalf("recovered %v, want [boom]", recovered)
+	}
+	if fired != 3 {
+		t.Fatalf("timer after panic fired %d times, want 3", fired)
+	}
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// TokenBucket 令牌桶，按照固定速率生成令牌，桶中最多保存burst个令牌
+type TokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	sync.Mutex
+}
+
+// NewTokenBucket 创建一个令牌桶，rate为每秒生成的令牌数量，初始时令牌桶是满的
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	return &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Allow 获取一个令牌，令牌不足时返回false
+func (b *TokenBucket) Allow() bool {
+	return b.AllowN(time.Now(), 1)
+}
+
+// AllowN 在指定时间获取n个令牌，令牌不足时返回false
+func (b *TokenBucket) AllowN(now time.Time, n int) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+
+	if b.tokens < float64(n) {
+		return false
+	}
+
+	b.tokens -= float64(n)
+	return true
+}
+
+// Tokens 当前令牌桶中的令牌数量
+func (b *TokenBucket) Tokens() float64 {
+	b.Lock()
+	defer b.Unlock()
+
+	return b.tokens
+}
@@ -1,6 +1,7 @@
 package gconf
 
 import (
+	"math"
 	"os"
 
 	"github.com/pkg/errors"
@@ -12,33 +13,95 @@ import (
 
 // ServerConfig gte框架内部配置
 type ServerConfig struct {
-	listenIP                  string
-	listenPort                int
-	networkVersion            string
-	readTry                   int
-	writeInternal             int
-	networkMode               int
-	maxConns                  int32
-	maxPacketSize             int
-	epollTimeout              int
-	epollEventSize            int
-	dispatcherQueues          int
-	dispatcherQueueLen        int
-	workersPerDispatcherQueue int
-	taskQueues                int
-	taskQueueLen              int
-	workersPerTaskQueue       int
-	websocketQueueLen         int
-	connSignalQueues          int
-	connSignalQueueLen        int
-	workersPerConnSignalQueue int
-	connShardCount            int
-	healthCheckInterval       int
-	logFilename               string // 日志文件存放目录
-	logMaxSize                int    // 文件大小限制,单位MB
-	logMaxBackups             int    // 最大保留日志文件数量
-	logMaxAge                 int    // 日志文件保留天数
-	logCompress               bool   // 是否压缩处理
+	listenIP                     string
+	listenPort                   int
+	networkVersion               string
+	readTry                      int
+	writeInternal                int
+	networkMode                  int
+	maxConns                     int32
+	maxConnsPerIP                int            // 单个IP的最大连接数，为0时不限制
+	cidrConnLimits               map[string]int // 网段的最大连接数，key: CIDR
+	acceptRate                   int            // 每秒接收连接的数量上限，为0时不限制
+	acceptBurst                  int            // 接收连接允许的突发数量
+	allowList                    []string       // 连接地址白名单，IP或CIDR，为空时不限制
+	denyList                     []string       // 连接地址黑名单，IP或CIDR
+	rejectRetryAfter             int            // 拒绝Websocket连接时建议客户端重试的间隔，单位秒
+	proxyProtocol                bool           // 是否解析可信代理发送的PROXY协议头部，开启后可信代理的TCP连接必须携带PROXY协议头部
+	proxyHeaderTimeout           int            // 读取PROXY协议头部的超时时间，单位毫秒
+	maxProxyHeaderReads          int            // 同时读取PROXY协议头部的最大连接数，超过时拒绝新连接
+	trustedProxies               []string       // 可信代理的地址列表，IP或CIDR，只有来自可信代理的PROXY协议头部与转发头部会被采信
+	realIPHeaders                []string       // Websocket连接获取客户端真实地址的请求头部，按顺序查找，支持X-Forwarded-For与X-Real-IP，为空时不开启
+	tcpNoDelay                   bool           // 是否关闭Nagle算法，关闭后小包立即发送，降低延迟
+	tcpKeepAlive                 bool           // 是否开启TCP保活探测
+	tcpKeepAliveIdle             int            // 连接空闲多久后开始发送保活探测，单位秒
+	tcpKeepAliveInterval         int            // 保活探测的间隔，单位秒
+	tcpKeepAliveCount            int            // 保活探测无响应多少次后断开连接
+	tcpRecvBuffer                int            // 接收缓冲区大小，单位字节，为0时使用系统默认值
+	tcpSendBuffer                int            // 发送缓冲区大小，单位字节，为0时使用系统默认值
+	tcpLinger                    int            // 关闭连接时等待未发送数据的时间，单位秒，小于0时使用系统默认行为，为0时丢弃未发送数据并发送RST
+	tcpUserTimeout               int            // 已发送数据未被确认的最长时间，超时后断开连接，单位毫秒，为0时使用系统默认值
+	tcpFastOpen                  int            // 监听套接字的TCP Fast Open队列长度，为0时不开启
+	tcpDeferAccept               int            // 监听套接字等待客户端发送数据后再接收连接的时间，单位秒，为0时不开启
+	adminAddr                    string         // 管理服务的监听地址，格式为host:port，为空时不开启管理服务
+	metricsPath                  string         // 管理服务输出Prometheus文本格式指标的路径
+	adminToken                   string         // 管理服务的Bearer令牌，为空时只允许本机回环地址访问管理服务
+	adminPprof                   bool           // 是否在管理服务中开启pprof与协程转储接口，开启后任务协程在执行处理函数期间按照消息ID设置pprof标签
+	traceHeader                  bool           // 是否在消息帧头部的消息ID之后携带24字节的链路上下文，依次为16字节的链路ID与8字节的跨度ID，客户端与服务端需要保持一致
+	slowHandlerThreshold         int            // 处理函数执行的慢请求阈值，单位毫秒，为0时不检测，路由可以单独设置
+	slowQueueThreshold           int            // 请求在任务队列中等待的慢请求阈值，单位毫秒，为0时不检测，路由可以单独设置
+	observerAsync                bool           // 观察函数是否在独立的协程中异步执行，异步执行时事件队列已满的事件被丢弃
+	observerQueueLen             int            // 异步观察事件队列的长度
+	observerWorkers              int            // 执行异步观察事件的协程数，大于1时不保证事件的顺序
+	maxPacketSize                int
+	epollTimeout                 int
+	epollEventSize               int
+	dispatcherQueues             int
+	dispatcherQueueLen           int
+	workersPerDispatcherQueue    int
+	minWorkersPerDispatcherQueue int
+	maxWorkersPerDispatcherQueue int
+	taskQueues                   int
+	taskQueueLen                 int
+	workersPerTaskQueue          int
+	taskSchedulePolicy           int
+	taskPriorityWeights          []int
+	minWorkersPerTaskQueue       int
+	maxWorkersPerTaskQueue       int
+	overloadPolicy               int
+	overloadMsgID                uint32
+	heartbeatMsgID               uint32 // 心跳消息ID，收到心跳消息时刷新连接的活跃状态并原样回复，不会进入任务处理流
+	workerAutoScale              bool
+	workerScaleInterval          int
+	workerScaleUpQueueLen        int
+	workerScaleUpWaitTime        int
+	workerScaleDownIdleRounds    int
+	websocketQueueLen            int
+	websocketPaths               []string // Websocket网关处理升级请求的路径
+	websocketOrigins             []string // 允许升级的请求来源，支持*通配符，为空时不限制，未携带Origin的请求不受限制
+	websocketSubprotocols        []string // 服务端支持的子协议，按优先级排列
+	websocketCompression         bool     // 是否协商permessage-deflate压缩
+	websocketCompressionLevel    int      // 压缩级别，取值范围为-2到9，参考compress/flate
+	websocketPingInterval        int      // 服务端主动发送Ping的间隔，单位毫秒，为0时不发送
+	connSignalQueues             int
+	connSignalQueueLen           int
+	workersPerConnSignalQueue    int
+	connShardCount               int
+	healthCheckInterval          int
+	idlePolicy                   int      // 连续多个巡检周期未收到数据时的处理策略
+	idleMaxMissed                int      // 判定连接不活跃的连续未收到数据的巡检周期数
+	readIdleTimeout              int      // 读空闲时间，单位毫秒，为0时不检测
+	writeIdleTimeout             int      // 写空闲时间，单位毫秒，为0时不检测
+	allIdleTimeout               int      // 读写空闲时间，单位毫秒，为0时不检测
+	timingWheelTick              int      // 时间轮的刻度精度，单位毫秒
+	timingWheelSize              int      // 每层时间轮的槽位数量
+	handshakeMsgIDs              []uint32 // 握手阶段允许访问的消息ID，为空时不开启握手阶段
+	handshakeTimeout             int      // 握手超时时间，单位毫秒
+	logFilename                  string   // 日志文件存放目录
+	logMaxSize                   int      // 文件大小限制,单位MB
+	logMaxBackups                int      // 最大保留日志文件数量
+	logMaxAge                    int      // 日志文件保留天数
+	logCompress                  bool     // 是否压缩处理
 }
 
 var _ trait.ServerConfig = (*ServerConfig)(nil)
@@ -48,31 +111,97 @@ var Config trait.ServerConfig = &ServerConfig{
 	listenIP:       "0.0.0.0",
 	listenPort:     8080,
 	networkVersion: "tcp4",
-	readTry:       1,
-	writeInternal: 100,
+	readTry:        1,
+	writeInternal:  100,
 	networkMode:    constant.TCPNetowrkMode,
 
 	maxConns:      1024,
 	maxPacketSize: 4096,
 
+	maxConnsPerIP:        0,
+	cidrConnLimits:       nil,
+	acceptRate:           0,
+	acceptBurst:          128,
+	allowList:            nil,
+	denyList:             nil,
+	rejectRetryAfter:     5,
+	proxyProtocol:        false,
+	proxyHeaderTimeout:   3000,
+	maxProxyHeaderReads:  1024,
+	trustedProxies:       nil,
+	realIPHeaders:        nil,
+	tcpNoDelay:           true,
+	tcpKeepAlive:         true,
+	tcpKeepAliveIdle:     15,
+	tcpKeepAliveInterval: 15,
+	tcpKeepAliveCount:    9,
+	tcpRecvBuffer:        0,
+	tcpSendBuffer:        0,
+	tcpLinger:            -1,
+	tcpUserTimeout:       0,
+	tcpFastOpen:          0,
+	tcpDeferAccept:       0,
+	adminAddr:            "",
+	metricsPath:          "/metrics",
+	adminToken:           "",
+	adminPprof:           false,
+	traceHeader:          false,
+	slowHandlerThreshold: 0,
+	slowQueueThreshold:   0,
+	observerAsync:        true,
+	observerQueueLen:     4096,
+	observerWorkers:      1,
+
 	epollTimeout:   -1,
 	epollEventSize: 128,
 
-	dispatcherQueues:          8,
-	dispatcherQueueLen:        128,
-	workersPerDispatcherQueue: 2,
-
-	taskQueues:          8,
-	taskQueueLen:        128,
-	workersPerTaskQueue: 4,
-
-	websocketQueueLen: 16,
+	dispatcherQueues:             8,
+	dispatcherQueueLen:           128,
+	workersPerDispatcherQueue:    2,
+	minWorkersPerDispatcherQueue: 1,
+	maxWorkersPerDispatcherQueue: 8,
+
+	taskQueues:             8,
+	taskQueueLen:           128,
+	workersPerTaskQueue:    4,
+	taskSchedulePolicy:     constant.WeightedPrioritySchedule,
+	taskPriorityWeights:    []int{8, 4, 1},
+	minWorkersPerTaskQueue: 1,
+	maxWorkersPerTaskQueue: 32,
+
+	overloadPolicy: constant.OverloadPause,
+	overloadMsgID:  math.MaxUint32,
+	heartbeatMsgID: math.MaxUint32 - 1,
+
+	workerAutoScale:           false,
+	workerScaleInterval:       1000,
+	workerScaleUpQueueLen:     32,
+	workerScaleUpWaitTime:     100,
+	workerScaleDownIdleRounds: 5,
+
+	websocketQueueLen:         16,
+	websocketPaths:            []string{"/"},
+	websocketOrigins:          nil,
+	websocketSubprotocols:     nil,
+	websocketCompression:      false,
+	websocketCompressionLevel: 1,
+	websocketPingInterval:     0,
 
 	connSignalQueues:          2,
 	connSignalQueueLen:        4,
 	workersPerConnSignalQueue: 2,
 	connShardCount:            16,
 	healthCheckInterval:       120000,
+	idlePolicy:                constant.IdleNotify,
+	idleMaxMissed:             2,
+	readIdleTimeout:           0,
+	writeIdleTimeout:          0,
+	allIdleTimeout:            0,
+	timingWheelTick:           10,
+	timingWheelSize:           64,
+
+	handshakeMsgIDs:  nil,
+	handshakeTimeout: 10000,
 
 	logFilename:   "./gte.log",
 	logMaxSize:    100,
@@ -143,6 +272,138 @@ func (c *ServerConfig) MaxConns() int32 {
 	return c.maxConns
 }
 
+func (c *ServerConfig) MaxConnsPerIP() int {
+	return c.maxConnsPerIP
+}
+
+func (c *ServerConfig) CIDRConnLimits() map[string]int {
+	return c.cidrConnLimits
+}
+
+func (c *ServerConfig) AcceptRate() int {
+	return c.acceptRate
+}
+
+func (c *ServerConfig) AcceptBurst() int {
+	return c.acceptBurst
+}
+
+func (c *ServerConfig) AllowList() []string {
+	return c.allowList
+}
+
+func (c *ServerConfig) DenyList() []string {
+	return c.denyList
+}
+
+func (c *ServerConfig) RejectRetryAfter() int {
+	return c.rejectRetryAfter
+}
+
+func (c *ServerConfig) ProxyProtocol() bool {
+	return c.proxyProtocol
+}
+
+func (c *ServerConfig) ProxyHeaderTimeout() int {
+	return c.proxyHeaderTimeout
+}
+
+func (c *ServerConfig) MaxProxyHeaderReads() int {
+	return c.maxProxyHeaderReads
+}
+
+func (c *ServerConfig) TrustedProxies() []string {
+	return c.trustedProxies
+}
+
+func (c *ServerConfig) RealIPHeaders() []string {
+	return c.realIPHeaders
+}
+
+func (c *ServerConfig) TCPNoDelay() bool {
+	return c.tcpNoDelay
+}
+
+func (c *ServerConfig) TCPKeepAlive() bool {
+	return c.tcpKeepAlive
+}
+
+func (c *ServerConfig) TCPKeepAliveIdle() int {
+	return c.tcpKeepAliveIdle
+}
+
+func (c *ServerConfig) TCPKeepAliveInterval() int {
+	return c.tcpKeepAliveInterval
+}
+
+func (c *ServerConfig) TCPKeepAliveCount() int {
+	return c.tcpKeepAliveCount
+}
+
+func (c *ServerConfig) TCPRecvBuffer() int {
+	return c.tcpRecvBuffer
+}
+
+func (c *ServerConfig) TCPSendBuffer() int {
+	return c.tcpSendBuffer
+}
+
+func (c *ServerConfig) TCPLinger() int {
+	return c.tcpLinger
+}
+
+func (c *ServerConfig) TCPUserTimeout() int {
+	return c.tcpUserTimeout
+}
+
+func (c *ServerConfig) TCPFastOpen() int {
+	return c.tcpFastOpen
+}
+
+func (c *ServerConfig) TCPDeferAccept() int {
+	return c.tcpDeferAccept
+}
+
+func (c *ServerConfig) AdminAddr() string {
+	return c.adminAddr
+}
+
+func (c *ServerConfig) MetricsPath() string {
+	return c.metricsPath
+}
+
+func (c *ServerConfig) AdminToken() string {
+	return c.adminToken
+}
+
+func (c *ServerConfig) AdminPprof() bool {
+	return c.adminPprof
+}
+
+func (c *ServerConfig) TraceHeader() bool {
+	return c.traceHeader
+}
+
+func (c *ServerConfig) SlowHandlerThreshold() int {
+	return c.slowHandlerThreshold
+}
+
+func (c *ServerConfig) SlowQueueThreshold() int {
+	return c.slowQueueThreshold
+}
+
+func (c *ServerConfig) ObserverAsync() bool {
+	return c.observerAsync
+}
+
+func (c *ServerConfig) ObserverQueueLen() int {
+	return c.observerQueueLen
+}
+
+func (c *ServerConfig) ObserverWorkers() int {
+	return c.observerWorkers
+}
+
 func (c *ServerConfig) MaxPacketSize() int {
 	return c.maxPacketSize
 }
@@ -167,6 +428,14 @@ func (c *ServerConfig) WorkersPerDispatcherQueue() int {
 	return c.workersPerDispatcherQueue
 }
 
+func (c *ServerConfig) MinWorkersPerDispatcherQueue() int {
+	return c.minWorkersPerDispatcherQueue
+}
+
+func (c *ServerConfig) MaxWorkersPerDispatcherQueue() int {
+	return c.maxWorkersPerDispatcherQueue
+}
+
 func (c *ServerConfig) TaskQueues() int {
 	return c.taskQueues
 }
@@ -179,10 +448,82 @@ func (c *ServerConfig) WorkersPerTaskQueue() int {
 	return c.workersPerTaskQueue
 }
 
+func (c *ServerConfig) TaskSchedulePolicy() int {
+	return c.taskSchedulePolicy
+}
+
+func (c *ServerConfig) TaskPriorityWeights() []int {
+	return c.taskPriorityWeights
+}
+
+func (c *ServerConfig) MinWorkersPerTaskQueue() int {
+	return c.minWorkersPerTaskQueue
+}
+
+func (c *ServerConfig) MaxWorkersPerTaskQueue() int {
+	return c.maxWorkersPerTaskQueue
+}
+
+func (c *ServerConfig) OverloadPolicy() int {
+	return c.overloadPolicy
+}
+
+func (c *ServerConfig) OverloadMsgID() uint32 {
+	return c.overloadMsgID
+}
+
+func (c *ServerConfig) HeartbeatMsgID() uint32 {
+	return c.heartbeatMsgID
+}
+
+func (c *ServerConfig) WorkerAutoScale() bool {
+	return c.workerAutoScale
+}
+
+func (c *ServerConfig) WorkerScaleInterval() int {
+	return c.workerScaleInterval
+}
+
+func (c *ServerConfig) WorkerScaleUpQueueLen() int {
+	return c.workerScaleUpQueueLen
+}
+
+func (c *ServerConfig) WorkerScaleUpWaitTime() int {
+	return c.workerScaleUpWaitTime
+}
+
+func (c *ServerConfig) WorkerScaleDownIdleRounds() int {
+	return c.workerScaleDownIdleRounds
+}
+
 func (c *ServerConfig) WebsocketQueueLen() int {
 	return c.websocketQueueLen
 }
 
+func (c *ServerConfig) WebsocketPaths() []string {
+	return c.websocketPaths
+}
+
+func (c *ServerConfig) WebsocketOrigins() []string {
+	return c.websocketOrigins
+}
+
+func (c *ServerConfig) WebsocketSubprotocols() []string {
+	return c.websocketSubprotocols
+}
+
+func (c *ServerConfig) WebsocketCompression() bool {
+	return c.websocketCompression
+}
+
+func (c *ServerConfig) WebsocketCompressionLevel() int {
+	return c.websocketCompressionLevel
+}
+
+func (c *ServerConfig) WebsocketPingInterval() int {
+	return c.websocketPingInterval
+}
+
 func (c *ServerConfig) ConnSignalQueues() int {
 	return c.connSignalQueues
 }
@@ -203,6 +544,42 @@ func (c *ServerConfig) HealthCheckInterval() int {
 	return c.healthCheckInterval
 }
 
+func (c *ServerConfig) IdlePolicy() int {
+	return c.idlePolicy
+}
+
+func (c *ServerConfig) IdleMaxMissed() int {
+	return c.idleMaxMissed
+}
+
+func (c *ServerConfig) ReadIdleTimeout() int {
+	return c.readIdleTimeout
+}
+
+func (c *ServerConfig) WriteIdleTimeout() int {
+	return c.writeIdleTimeout
+}
+
+func (c *ServerConfig) AllIdleTimeout() int {
+	return c.allIdleTimeout
+}
+
+func (c *ServerConfig) TimingWheelTick() int {
+	return c.timingWheelTick
+}
+
+func (c *ServerConfig) TimingWheelSize() int {
+	return c.timingWheelSize
+}
+
+func (c *ServerConfig) HandshakeMsgIDs() []uint32 {
+	return c.handshakeMsgIDs
+}
+
+func (c *ServerConfig) HandshakeTimeout() int {
+	return c.handshakeTimeout
+}
+
 func (c *ServerConfig) LogFilename() string {
 	return c.logFilename
 }
@@ -258,6 +635,171 @@ func (c *ServerConfig) WithMaxConns(maxConns int32) trait.ServerConfig {
 	return c
 }
 
+func (c *ServerConfig) WithMaxConnsPerIP(maxConnsPerIP int) trait.ServerConfig {
+	c.maxConnsPerIP = maxConnsPerIP
+	return c
+}
+
+func (c *ServerConfig) WithCIDRConnLimits(cidrConnLimits map[string]int) trait.ServerConfig {
+	c.cidrConnLimits = cidrConnLimits
+	return c
+}
+
+func (c *ServerConfig) WithAcceptRate(acceptRate int) trait.ServerConfig {
+	c.acceptRate = acceptRate
+	return c
+}
+
+func (c *ServerConfig) WithAcceptBurst(acceptBurst int) trait.ServerConfig {
+	c.acceptBurst = acceptBurst
+	return c
+}
+
+func (c *ServerConfig) WithAllowList(allowList []string) trait.ServerConfig {
+	c.allowList = allowList
+	return c
+}
+
+func (c *ServerConfig) WithDenyList(denyList []string) trait.ServerConfig {
+	c.denyList = denyList
+	return c
+}
+
+func (c *ServerConfig) WithRejectRetryAfter(rejectRetryAfter int) trait.ServerConfig {
+	c.rejectRetryAfter = rejectRetryAfter
+	return c
+}
+
+func (c *ServerConfig) WithProxyProtocol(proxyProtocol bool) trait.ServerConfig {
+	c.proxyProtocol = proxyProtocol
+	return c
+}
+
+func (c *ServerConfig) WithProxyHeaderTimeout(proxyHeaderTimeout int) trait.ServerConfig {
+	c.proxyHeaderTimeout = proxyHeaderTimeout
+	return c
+}
+
+func (c *ServerConfig) WithMaxProxyHeaderReads(maxProxyHeaderReads int) trait.ServerConfig {
+	c.maxProxyHeaderReads = maxProxyHeaderReads
+	return c
+}
+
+func (c *ServerConfig) WithTrustedProxies(trustedProxies []string) trait.ServerConfig {
+	c.trustedProxies = trustedProxies
+	return c
+}
+
+func (c *ServerConfig) WithRealIPHeaders(realIPHeaders []string) trait.ServerConfig {
+	c.realIPHeaders = realIPHeaders
+	return c
+}
+
+func (c *ServerConfig) WithTCPNoDelay(tcpNoDelay bool) trait.ServerConfig {
+	c.tcpNoDelay = tcpNoDelay
+	return c
+}
+
+func (c *ServerConfig) WithTCPKeepAlive(tcpKeepAlive bool) trait.ServerConfig {
+	c.tcpKeepAlive = tcpKeepAlive
+	return c
+}
+
+func (c *ServerConfig) WithTCPKeepAliveIdle(tcpKeepAliveIdle int) trait.ServerConfig {
+	c.tcpKeepAliveIdle = tcpKeepAliveIdle
+	return c
+}
+
+func (c *ServerConfig) WithTCPKeepAliveInterval(tcpKeepAliveInterval int) trait.ServerConfig {
+	c.tcpKeepAliveInterval = tcpKeepAliveInterval
+	return c
+}
+
+func (c *ServerConfig) WithTCPKeepAliveCount(tcpKeepAliveCount int) trait.ServerConfig {
+	c.tcpKeepAliveCount = tcpKeepAliveCount
+	return c
+}
+
+func (c *ServerConfig) WithTCPRecvBuffer(tcpRecvBuffer int) trait.ServerConfig {
+	c.tcpRecvBuffer = tcpRecvBuffer
+	return c
+}
+
+func (c *ServerConfig) WithTCPSendBuffer(tcpSendBuffer int) trait.ServerConfig {
+	c.tcpSendBuffer = tcpSendBuffer
+	return c
+}
+
+func (c *ServerConfig) WithTCPLinger(tcpLinger int) trait.ServerConfig {
+	c.tcpLinger = tcpLinger
+	return c
+}
+
+func (c *ServerConfig) WithTCPUserTimeout(tcpUserTimeout int) trait.ServerConfig {
+	c.tcpUserTimeout = tcpUserTimeout
+	return c
+}
+
+func (c *ServerConfig) WithTCPFastOpen(tcpFastOpen int) trait.ServerConfig {
+	c.tcpFastOpen = tcpFastOpen
+	return c
+}
+
+func (c *ServerConfig) WithTCPDeferAccept(tcpDeferAccept int) trait.ServerConfig {
+	c.tcpDeferAccept = tcpDeferAccept
+	return c
+}
+
+func (c *ServerConfig) WithAdminAddr(adminAddr string) trait.ServerConfig {
+	c.adminAddr = adminAddr
+	return c
+}
+
+func (c *ServerConfig) WithMetricsPath(metricsPath string) trait.ServerConfig {
+	c.metricsPath = metricsPath
+	return c
+}
+
+func (c *ServerConfig) WithAdminToken(adminToken string) trait.ServerConfig {
+	c.adminToken = adminToken
+	return c
+}
+
+func (c *ServerConfig) WithAdminPprof(adminPprof bool) trait.ServerConfig {
+	c.adminPprof = adminPprof
+	return c
+}
+
+func (c *ServerConfig) WithTraceHeader(traceHeader bool) trait.ServerConfig {
+	c.traceHeader = traceHeader
+	return c
+}
+
+func (c *ServerConfig) WithSlowHandlerThreshold(slowHandlerThreshold int) trait.ServerConfig {
+	c.slowHandlerThreshold = slowHandlerThreshold
+	return c
+}
+
+func (c *ServerConfig) WithSlowQueueThreshold(slowQueueThreshold int) trait.ServerConfig {
+	c.slowQueueThreshold = slowQueueThreshold
+	return c
+}
+
+func (c *ServerConfig) WithObserverAsync(observerAsync bool) trait.ServerConfig {
+	c.observerAsync = observerAsync
+	return c
+}
+
+func (c *ServerConfig) WithObserverQueueLen(observerQueueLen int) trait.ServerConfig {
+	c.observerQueueLen = observerQueueLen
+	return c
+}
+
+func (c *ServerConfig) WithObserverWorkers(observerWorkers int) trait.ServerConfig {
+	c.observerWorkers = observerWorkers
+	return c
+}
+
 func (c *ServerConfig) WithMaxPacketSize(maxPacketSize int) trait.ServerConfig {
 	c.maxPacketSize = maxPacketSize
 	return c
@@ -288,6 +830,16 @@ func (c *ServerConfig) WithWorkersPerDispatcherQueue(workersPerDispatcherQueue i
 	return c
 }
 
+func (c *ServerConfig) WithMinWorkersPerDispatcherQueue(minWorkersPerDispatcherQueue int) trait.ServerConfig {
+	c.minWorkersPerDispatcherQueue = minWorkersPerDispatcherQueue
+	return c
+}
+
+func (c *ServerConfig) WithMaxWorkersPerDispatcherQueue(maxWorkersPerDispatcherQueue int) trait.ServerConfig {
+	c.maxWorkersPerDispatcherQueue = maxWorkersPerDispatcherQueue
+	return c
+}
+
 func (c *ServerConfig) WithTaskQueues(taskQueues int) trait.ServerConfig {
 	c.taskQueues = taskQueues
 	return c
@@ -303,11 +855,101 @@ func (c *ServerConfig) WithWorkersPerTaskQueue(workersPerTaskQueue int) trait.Se
 	return c
 }
 
+func (c *ServerConfig) WithTaskSchedulePolicy(taskSchedulePolicy int) trait.ServerConfig {
+	c.taskSchedulePolicy = taskSchedulePolicy
+	return c
+}
+
+func (c *ServerConfig) WithTaskPriorityWeights(taskPriorityWeights []int) trait.ServerConfig {
+	c.taskPriorityWeights = taskPriorityWeights
+	return c
+}
+
+func (c *ServerConfig) WithMinWorkersPerTaskQueue(minWorkersPerTaskQueue int) trait.ServerConfig {
+	c.minWorkersPerTaskQueue = minWorkersPerTaskQueue
+	return c
+}
+
+func (c *ServerConfig) WithMaxWorkersPerTaskQueue(maxWorkersPerTaskQueue int) trait.ServerConfig {
+	c.maxWorkersPerTaskQueue = maxWorkersPerTaskQueue
+	return c
+}
+
+func (c *ServerConfig) WithOverloadPolicy(overloadPolicy int) trait.ServerConfig {
+	c.overloadPolicy = overloadPolicy
+	return c
+}
+
+func (c *ServerConfig) WithOverloadMsgID(overloadMsgID uint32) trait.ServerConfig {
+	c.overloadMsgID = overloadMsgID
+	return c
+}
+
+func (c *ServerConfig) WithHeartbeatMsgID(heartbeatMsgID uint32) trait.ServerConfig {
+	c.heartbeatMsgID = heartbeatMsgID
+	return c
+}
+
+func (c *ServerConfig) WithWorkerAutoScale(workerAutoScale bool) trait.ServerConfig {
+	c.workerAutoScale = workerAutoScale
+	return c
+}
+
+func (c *ServerConfig) WithWorkerScaleInterval(workerScaleInterval int) trait.ServerConfig {
+	c.workerScaleInterval = workerScaleInterval
+	return c
+}
+
+func (c *ServerConfig) WithWorkerScaleUpQueueLen(workerScaleUpQueueLen int) trait.ServerConfig {
+	c.workerScaleUpQueueLen = workerScaleUpQueueLen
+	return c
+}
+
+func (c *ServerConfig) WithWorkerScaleUpWaitTime(workerScaleUpWaitTime int) trait.ServerConfig {
+	c.workerScaleUpWaitTime = workerScaleUpWaitTime
+	return c
+}
+
+func (c *ServerConfig) WithWorkerScaleDownIdleRounds(workerScaleDownIdleRounds int) trait.ServerConfig {
+	c.workerScaleDownIdleRounds = workerScaleDownIdleRounds
+	return c
+}
+
 func (c *ServerConfig) WithWebsocketQueueLen(websocketQueueLen int) trait.ServerConfig {
 	c.websocketQueueLen = websocketQueueLen
 	return c
 }
 
+func (c *ServerConfig) WithWebsocketPaths(websocketPaths []string) trait.ServerConfig {
+	c.websocketPaths = websocketPaths
+	return c
+}
+
+func (c *ServerConfig) WithWebsocketOrigins(websocketOrigins []string) trait.ServerConfig {
+	c.websocketOrigins = websocketOrigins
+	return c
+}
+
+func (c *ServerConfig) WithWebsocketSubprotocols(websocketSubprotocols []string) trait.ServerConfig {
+	c.websocketSubprotocols = websocketSubprotocols
+	return c
+}
+
+func (c *ServerConfig) WithWebsocketCompression(websocketCompression bool) trait.ServerConfig {
+	c.websocketCompression = websocketCompression
+	return c
+}
+
+func (c *ServerConfig) WithWebsocketCompressionLevel(websocketCompressionLevel int) trait.ServerConfig {
+	c.websocketCompressionLevel = websocketCompressionLevel
+	return c
+}
+
+func (c *ServerConfig) WithWebsocketPingInterval(websocketPingInterval int) trait.ServerConfig {
+	c.websocketPingInterval = websocketPingInterval
+	return c
+}
+
 func (c *ServerConfig) WithConnSignalQueues(connSignalQueues int) trait.ServerConfig {
 	c.connSignalQueues = connSignalQueues
 	return c
@@ -333,6 +975,51 @@ func (c *ServerConfig) WithHealthCheckInterval(healthCheckInterval int) trait.Se
 	return c
 }
 
+func (c *ServerConfig) WithIdlePolicy(idlePolicy int) trait.ServerConfig {
+	c.idlePolicy = idlePolicy
+	return c
+}
+
+func (c *ServerConfig) WithIdleMaxMissed(idleMaxMissed int) trait.ServerConfig {
+	c.idleMaxMissed = idleMaxMissed
+	return c
+}
+
+func (c *ServerConfig) WithReadIdleTimeout(readIdleTimeout int) trait.ServerConfig {
+	c.readIdleTimeout = readIdleTimeout
+	return c
+}
+
+func (c *ServerConfig) WithWriteIdleTimeout(writeIdleTimeout int) trait.ServerConfig {
+	c.writeIdleTimeout = writeIdleTimeout
+	return c
+}
+
+func (c *ServerConfig) WithAllIdleTimeout(allIdleTimeout int) trait.ServerConfig {
+	c.allIdleTimeout = allIdleTimeout
+	return c
+}
+
+func (c *ServerConfig) WithTimingWheelTick(timingWheelTick int) trait.ServerConfig {
+	c.timingWheelTick = timingWheelTick
+	return c
+}
+
+func (c *ServerConfig) WithTimingWheelSize(timingWheelSize int) trait.ServerConfig {
+	c.timingWheelSize = timingWheelSize
+	return c
+}
+
+func (c *ServerConfig) WithHandshakeMsgIDs(handshakeMsgIDs []uint32) trait.ServerConfig {
+	c.handshakeMsgIDs = handshakeMsgIDs
+	return c
+}
+
+func (c *ServerConfig) WithHandshakeTimeout(handshakeTimeout int) trait.ServerConfig {
+	c.handshakeTimeout = handshakeTimeout
+	return c
+}
+
 func (c *ServerConfig) WithLogFilename(logFilename string) trait.ServerConfig {
 	c.logFilename = logFilename
 	return c
@@ -0,0 +1,366 @@
+package gcore
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/zm50/gte/constant"
+	"github.com/zm50/gte/gconf"
+	"github.com/zm50/gte/glog"
+	"github.com/zm50/gte/trait"
+)
+
+const (
+	// 连接列表默认与最大的分页大小
+	defaultConnPageSize = 100
+	maxConnPageSize     = 1000
+	// 连接属性摘要的最大长度
+	maxPropertySummaryLen = 128
+)
+
+// ErrConnNotFound 连接不存在或已关闭
+var ErrConnNotFound = errors.New("connection not found")
+
+var stateNames = map[uint32]string{
+	constant.ConnActiveState:    "active",
+	constant.ConnInspectState:   "inspect",
+	constant.ConnCloseState:     "close",
+	constant.ConnNotActiveState: "not_active",
+}
+
+// secretConfigs 输出生效配置时需要隐藏的配置项
+var secretConfigs = []string{"AdminToken"}
+
+// ConnInfo 管理接口输出的连接信息
+type ConnInfo struct {
+	ID            uint64    `json:"id"`
+	RemoteAddr    string    `json:"remote_addr"`
+	State         string    `json:"state"`
+	Network       string    `json:"network"`
+	Listener      string    `json:"listener"`
+	StartTime     time.Time `json:"start_time"`
+	AgeSeconds    float64   `json:"age_seconds"`
+	BytesIn       uint64    `json:"bytes_in"`
+	BytesOut      uint64    `json:"bytes_out"`
+	LastReadTime  time.Time `json:"last_read_time"`
+	LastWriteTime time.Time `json:"last_write_time"`
+	Authenticated bool      `json:"authenticated"`
+	Property      string    `json:"property"`
+}
+
+// RouteInfo 管理接口输出的路由信息
+type RouteInfo struct {
+	ID        uint32   `json:"id"`
+	Name      string   `json:"name"`
+	Desc      string   `json:"desc"`
+	Group     string   `json:"group"`
+	Priority  int      `json:"priority"`
+	Enabled   bool     `json:"enabled"`
+	TimeoutMs int64    `json:"timeout_ms"`
+	Handlers  []string `json:"handlers"`
+	// 慢请求阈值，为0时使用全局配置，小于0时不检测
+	SlowHandlerMs int64 `json:"slow_handler_ms"`
+	SlowQueueMs   int64 `json:"slow_queue_ms"`
+}
+
+// QueueInfo 管理接口输出的工作协程数与队列深度
+type QueueInfo struct {
+	OnlineConns       int32             `json:"online_conns"`
+	DispatcherWorkers int               `json:"dispatcher_workers"`
+	DispatcherPending int               `json:"dispatcher_pending"`
+	TaskWorkers       int               `json:"task_workers"`
+	TaskPending       map[string]int    `json:"task_pending"`
+	ConnSignalPending int               `json:"conn_signal_pending"`
+	OverloadCounts    map[string]uint64 `json:"overload_counts"`
+	RejectCounts      map[string]uint64 `json:"reject_counts"`
+	ObserverDropped   map[string]uint64 `json:"observer_dropped"`
+}
+
+// broadcastRequest 广播管理消息的请求体
+type broadcastRequest struct {
+	MsgID uint32 `json:"msg_id"`
+	Data  string `json:"data"`
+}
+
+// newConnInfo 获取连接的信息
+func newConnInfo[T any](conn trait.Connection[T], now time.Time) ConnInfo {
+	network := "tcp"
+	if _, ok := conn.(*WebsocketConnection[T]); ok {
+		network = "websocket"
+	}
+
+	property := fmt.Sprintf("%+v", conn.Property())
+	if len(property) > maxPropertySummaryLen {
+		property = property[:maxPropertySummaryLen] + "..."
+	}
+
+	return ConnInfo{
+		ID:            conn.ID(),
+		RemoteAddr:    conn.RemoteAddr().String(),
+		State:         stateNames[conn.State()],
+		Network:       network,
+		Listener:      conn.LocalAddr().String(),
+		StartTime:     conn.StartTime(),
+		AgeSeconds:    now.Sub(conn.StartTime()).Seconds(),
+		BytesIn:       conn.BytesIn(),
+		BytesOut:      conn.BytesOut(),
+		LastReadTime:  conn.LastReadTime(),
+		LastWriteTime: conn.LastWriteTime(),
+		Authenticated: conn.IsAuthenticated(),
+		Property:      property,
+	}
+}
+
+// Kick 通过连接ID关闭连接，触发连接断开的钩子回调
+func (e *Engine[T]) Kick(connID uint64) error {
+	conn, ok := e.connMgr.Get(int32(connID))
+	if !ok {
+		return ErrConnNotFound
+	}
+
+	glog.Infof("kick conn %d\n", connID)
+
+	return e.connMgr.Close(int32(conn.ID()), constant.CloseByKick)
+}
+
+// Broadcast 向所有在线连接发送消息，返回发送成功的连接数量
+func (e *Engine[T]) Broadcast(msgID uint32, data []byte) int {
+	sent := 0
+	for _, conn := range e.connMgr.Conns() {
+		err := conn.SendMsg(msgID, data)
+		if err != nil {
+			glog.Errorf("broadcast to conn %d err: %v\n", conn.ID(), err)
+			continue
+		}
+
+		sent++
+	}
+
+	return sent
+}
+
+// registerAdminAPI 在管理服务中注册连接、路由、队列与配置的JSON接口
+func (e *Engine[T]) registerAdminAPI(admin *AdminServer) {
+	admin.HandleFunc("GET /conns", e.listConns)
+	admin.HandleFunc("GET /conns/{id}", e.getConn)
+	admin.HandleFunc("POST /conns/{id}/kick", admin.mutating(e.kickConn))
+	admin.HandleFunc("POST /broadcast", admin.mutating(e.broadcast))
+	admin.HandleFunc("GET /routes", e.listRoutes)
+	admin.HandleFunc("GET /queues", e.queues)
+	admin.HandleFunc("GET /config", e.config)
+}
+
+// listConns 按照连接ID排序分页输出在线连接，分页参数为offset与limit
+func (e *Engine[T]) listConns(w http.ResponseWriter, r *http.Request) {
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		writeError(w, http.StatusBadRequest, "invalid offset")
+		return
+	}
+
+	limit, err := queryInt(r, "limit", defaultConnPageSize)
+	if err != nil || limit <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid limit")
+		return
+	}
+	limit = min(limit, maxConnPageSize)
+
+	conns := e.connMgr.Conns()
+	slices.SortFunc(conns, func(a, b trait.Connection[T]) int {
+		return cmp.Compare(a.ID(), b.ID())
+	})
+
+	// 分别限制在连接数以内后再相加，避免offset过大时溢出
+	start := min(offset, len(conns))
+	end := start + min(limit, len(conns)-start)
+
+	now := time.Now()
+	infos := make([]ConnInfo, 0, end-start)
+	for _, conn := range conns[start:end] {
+		infos = append(infos, newConnInfo(conn, now))
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"total": len(conns),
+		"conns": infos,
+	})
+}
+
+// getConn 输出单个连接的信息
+func (e *Engine[T]) getConn(w http.ResponseWriter, r *http.Request) {
+	conn, ok := e.pathConn(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newConnInfo(conn, time.Now()))
+}
+
+// kickConn 关闭路径中指定的连接
+func (e *Engine[T]) kickConn(w http.ResponseWriter, r *http.Request) {
+	conn, ok := e.pathConn(w, r)
+	if !ok {
+		return
+	}
+
+	err := e.Kick(conn.ID())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"kicked": conn.ID()})
+}
+
+// broadcast 向所有在线连接发送管理消息
+func (e *Engine[T]) broadcast(w http.ResponseWriter, r *http.Request) {
+	var req broadcastRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	sent := e.Broadcast(req.MsgID, []byte(req.Data))
+
+	writeJSON(w, http.StatusOK, map[string]any{"sent": sent})
+}
+
+// listRoutes 输出路由表
+func (e *Engine[T]) listRoutes(w http.ResponseWriter, r *http.Request) {
+	routes := e.Routes()
+	slices.SortFunc(routes, func(a, b trait.Route[T]) int {
+		return cmp.Compare(a.ID(), b.ID())
+	})
+
+	infos := make([]RouteInfo, 0, len(routes))
+	for _, route := range routes {
+		infos = append(infos, RouteInfo{
+			ID:        route.ID(),
+			Name:      route.Name(),
+			Desc:      route.Desc(),
+			Group:     route.Group(),
+			Priority:  route.Priority(),
+			Enabled:   route.Enabled(),
+			TimeoutMs: route.Timeout().Milliseconds(),
+			Handlers:  route.Handlers(),
+
+			SlowHandlerMs: route.SlowHandlerThreshold().Milliseconds(),
+			SlowQueueMs:   route.SlowQueueThreshold().Milliseconds(),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, infos)
+}
+
+// queues 输出工作协程数、队列深度与过载、拒绝、丢弃观察事件的次数
+func (e *Engine[T]) queues(w http.ResponseWriter, r *http.Request) {
+	info := QueueInfo{
+		OnlineConns:       e.connMgr.OnlineConns(),
+		DispatcherWorkers: e.DispatcherWorkers(),
+		DispatcherPending: e.connMgr.Dispatcher().PendingConns(),
+		TaskWorkers:       e.TaskWorkers(),
+		TaskPending:       make(map[string]int),
+		ConnSignalPending: e.connMgr.PendingConnSignals(),
+		OverloadCounts: map[string]uint64{
+			"pause":      e.OverloadCount(constant.OverloadPause),
+			"drop":       e.OverloadCount(constant.OverloadDrop),
+			"disconnect": e.OverloadCount(constant.OverloadDisconnect),
+		},
+		RejectCounts:    make(map[string]uint64),
+		ObserverDropped: make(map[string]uint64),
+	}
+
+	for priority, name := range priorityNames {
+		info.TaskPending[name] = e.PendingTasks(priority)
+	}
+
+	for reason, name := range rejectReasonNames {
+		info.RejectCounts[name] = e.RejectCount(reason)
+	}
+
+	for event, name := range observeEventNames {
+		info.ObserverDropped[name] = e.ObserverDropped(event)
+	}
+
+	writeJSON(w, http.StatusOK, info)
+}
+
+// config 输出生效的配置，通过反射调用配置的所有读取方法，隐藏令牌等敏感配置
+func (e *Engine[T]) config(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, effectiveConfig(gconf.Config))
+}
+
+// effectiveConfig 获取配置所有无参数且只有一个返回值的读取方法的返回值
+func effectiveConfig(config trait.ServerConfig) map[string]any {
+	v := reflect.ValueOf(config)
+	t := v.Type()
+
+	configs := make(map[string]any)
+	for i := 0; i < t.NumMethod(); i++ {
+		method := t.Method(i)
+		if strings.HasPrefix(method.Name, "With") || method.Type.NumIn() != 1 || method.Type.NumOut() != 1 {
+			continue
+		}
+
+		if slices.Contains(secretConfigs, method.Name) {
+			configs[method.Name] = "******"
+			continue
+		}
+
+		configs[method.Name] = v.Method(i).Call(nil)[0].Interface()
+	}
+
+	return configs
+}
+
+// pathConn 获取路径参数id对应的连接，连接不存在时输出404
+func (e *Engine[T]) pathConn(w http.ResponseWriter, r *http.Request) (trait.Connection[T], bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid connection id")
+		return nil, false
+	}
+
+	conn, ok := e.connMgr.Get(int32(id))
+	if !ok {
+		writeError(w, http.StatusNotFound, ErrConnNotFound.Error())
+		return nil, false
+	}
+
+	return conn, true
+}
+
+// queryInt 读取整数查询参数，参数为空时返回默认值
+func queryInt(r *http.Request, key string, defaultValue int) (int, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	return strconv.Atoi(value)
+}
+
+// writeJSON 输出JSON响应
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		glog.Error("write admin response error:", err)
+	}
+}
+
+// writeError 输出JSON格式的错误响应
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
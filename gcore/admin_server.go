@@ -0,0 +1,141 @@
+package gcore
+
+import (
+	"crypto/subtle"
+	"errors"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/zm50/gte/glog"
+)
+
+// AdminServer 管理服务，在独立的端口上提供指标、连接管理等运维接口
+// 配置了令牌时所有接口需要携带Bearer令牌，未配置令牌时只允许本机回环地址以本机的Host访问，且不开放修改服务状态的接口
+type AdminServer struct {
+	address string
+	token   string
+	mux     *http.ServeMux
+	server  *http.Server
+}
+
+// NewAdminServer 创建管理服务，token为空时只允许本机回环地址访问，修改服务状态的接口拒绝访问
+func NewAdminServer(address string, token string) *AdminServer {
+	s := &AdminServer{
+		address: address,
+		token:   token,
+		mux:     http.NewServeMux(),
+	}
+	s.server = &http.Server{Addr: address, Handler: s}
+
+	return s
+}
+
+// ServeHTTP 校验请求的访问权限后交给注册的管理接口处理
+func (s *AdminServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.token == "" && !loopbackHost(r.Host) {
+		// 未配置令牌时只接受本机的Host，防止浏览器通过DNS重绑定访问本机的管理服务
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="gte admin"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	s.mux.ServeHTTP(w, r)
+}
+
+// authorized 校验请求的Bearer令牌，未配置令牌时校验请求是否来自本机回环地址
+func (s *AdminServer) authorized(r *http.Request) bool {
+	if s.token == "" {
+		ip := hostIP(r.RemoteAddr)
+		return ip != nil && ip.IsLoopback()
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// mutating 包装修改服务状态的管理接口，防止浏览器发起的跨站请求
+// 未配置令牌时拒绝访问，请求需要携带JSON内容类型，携带Origin时需要与Host同源
+func (s *AdminServer) mutating(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.token == "" {
+			writeError(w, http.StatusForbidden, "mutating admin endpoints require an admin token")
+			return
+		}
+
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != "application/json" {
+			writeError(w, http.StatusUnsupportedMediaType, "content type must be application/json")
+			return
+		}
+
+		if origin := r.Header.Get("Origin"); origin != "" {
+			u, err := url.Parse(origin)
+			if err != nil || !strings.EqualFold(u.Host, r.Host) {
+				writeError(w, http.StatusForbidden, "cross-origin request rejected")
+				return
+			}
+		}
+
+		handler(w, r)
+	}
+}
+
+// loopbackHost 请求的Host是否为本机回环地址或localhost
+func loopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+// Handle 注册管理接口
+func (s *AdminServer) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// HandleFunc 注册管理接口的处理函数
+func (s *AdminServer) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	s.mux.HandleFunc(pattern, handler)
+}
+
+// ListenAndServe 监听管理服务的端口，管理服务停止时返回nil
+func (s *AdminServer) ListenAndServe() error {
+	ln, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return err
+	}
+
+	glog.Infof("admin server listening on %s\n", ln.Addr())
+
+	if s.token == "" {
+		if ip, ok := ln.Addr().(*net.TCPAddr); ok && !ip.IP.IsLoopback() {
+			glog.Warnf("admin server listening on non-loopback address %s without token, only loopback clients are allowed and mutating endpoints are disabled\n", ln.Addr())
+		}
+	}
+
+	err = s.server.Serve(ln)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// Stop 停止管理服务
+func (s *AdminServer) Stop() error {
+	return s.server.Close()
+}
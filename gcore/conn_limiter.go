@@ -0,0 +1,257 @@
+package gcore
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"github.com/zm50/gte/constant"
+	"github.com/zm50/gte/core"
+	"github.com/zm50/gte/gconf"
+	"github.com/zm50/gte/trait"
+)
+
+// cidrLimit 网段的连接数限制
+type cidrLimit struct {
+	ipNet    *net.IPNet
+	maxConns int
+	conns    int
+}
+
+// ConnLimiter 连接准入限制，限制单个IP与网段的连接数、接收连接的速率，并基于黑白名单过滤连接
+type ConnLimiter struct {
+	maxConnsPerIP int
+	// key: IP, value: 连接数
+	ipConns    map[string]int
+	cidrLimits []*cidrLimit
+	lock       sync.Mutex
+
+	acceptBucket *core.TokenBucket
+
+	// 黑白名单，运行期间可以整体替换
+	allowList atomic.Pointer[[]*net.IPNet]
+	denyList  atomic.Pointer[[]*net.IPNet]
+
+	// 各拒绝原因的拒绝次数
+	rejectCounts [constant.RejectReasons]atomic.Uint64
+
+	metrics *engineMetrics
+}
+
+var _ trait.ConnLimiter = (*ConnLimiter)(nil)
+
+// NewConnLimiter 基于配置创建连接准入限制，metrics为引擎的指标
+func NewConnLimiter(metrics *engineMetrics) (*ConnLimiter, error) {
+	l := &ConnLimiter{
+		maxConnsPerIP: gconf.Config.MaxConnsPerIP(),
+		ipConns:       make(map[string]int),
+		metrics:       metrics,
+	}
+
+	for cidr, maxConns := range gconf.Config.CIDRConnLimits() {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "parse cidr conn limit %s failed", cidr)
+		}
+
+		l.cidrLimits = append(l.cidrLimits, &cidrLimit{ipNet: ipNet, maxConns: maxConns})
+	}
+
+	if gconf.Config.AcceptRate() > 0 {
+		l.acceptBucket = core.NewTokenBucket(float64(gconf.Config.AcceptRate()), gconf.Config.AcceptBurst())
+	}
+
+	err := l.SetAllowList(gconf.Config.AllowList())
+	if err != nil {
+		return nil, err
+	}
+
+	err = l.SetDenyList(gconf.Config.DenyList())
+	if err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// AllowAccept 检查接收连接的速率，超过限制时记录拒绝，在读取客户端地址等可能阻塞的操作前调用
+func (l *ConnLimiter) AllowAccept() bool {
+	if l.acceptBucket != nil && !l.acceptBucket.Allow() {
+		l.Reject(constant.RejectAcceptRate)
+		return false
+	}
+
+	return true
+}
+
+// Acquire 检查连接地址是否允许接入，允许时占用一个连接数，连接关闭后需要调用Release释放，拒绝时返回拒绝原因
+// 接收连接的速率由AllowAccept单独检查
+func (l *ConnLimiter) Acquire(ip net.IP) (bool, int) {
+	if !l.permitted(ip) {
+		l.Reject(constant.RejectDenied)
+		return false, constant.RejectDenied
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	key := ip.String()
+	if l.maxConnsPerIP > 0 && l.ipConns[key] >= l.maxConnsPerIP {
+		l.Reject(constant.RejectIPLimit)
+		return false, constant.RejectIPLimit
+	}
+
+	for _, limit := range l.cidrLimits {
+		if limit.ipNet.Contains(ip) && limit.conns >= limit.maxConns {
+			l.Reject(constant.RejectCIDRLimit)
+			return false, constant.RejectCIDRLimit
+		}
+	}
+
+	l.ipConns[key]++
+	for _, limit := range l.cidrLimits {
+		if limit.ipNet.Contains(ip) {
+			limit.conns++
+		}
+	}
+
+	return true, 0
+}
+
+// Release 释放连接地址占用的连接数
+func (l *ConnLimiter) Release(ip net.IP) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	key := ip.String()
+	if l.ipConns[key] <= 1 {
+		delete(l.ipConns, key)
+	} else {
+		l.ipConns[key]--
+	}
+
+	for _, limit := range l.cidrLimits {
+		if limit.ipNet.Contains(ip) && limit.conns > 0 {
+			limit.conns--
+		}
+	}
+}
+
+// Reject 记录一次连接拒绝
+func (l *ConnLimiter) Reject(reason int) {
+	l.rejectCounts[reason].Add(1)
+	l.metrics.connsRejected.Add(1, rejectReasonNames[reason])
+}
+
+// RejectCount 获取拒绝原因对应的拒绝次数
+func (l *ConnLimiter) RejectCount(reason int) uint64 {
+	if reason < 0 || reason >= constant.RejectReasons {
+		return 0
+	}
+
+	return l.rejectCounts[reason].Load()
+}
+
+// SetAllowList 替换白名单，白名单为空时不限制，运行期间可以调用以重新加载
+func (l *ConnLimiter) SetAllowList(list []string) error {
+	ipNets, err := parseIPNets(list)
+	if err != nil {
+		return err
+	}
+
+	l.allowList.Store(&ipNets)
+
+	return nil
+}
+
+// SetDenyList 替换黑名单，运行期间可以调用以重新加载
+func (l *ConnLimiter) SetDenyList(list []string) error {
+	ipNets, err := parseIPNets(list)
+	if err != nil {
+		return err
+	}
+
+	l.denyList.Store(&ipNets)
+
+	return nil
+}
+
+// permitted 基于黑白名单判断连接地址是否允许接入
+func (l *ConnLimiter) permitted(ip net.IP) bool {
+	if containsIP(*l.denyList.Load(), ip) {
+		return false
+	}
+
+	allowList := *l.allowList.Load()
+	return len(allowList) == 0 || containsIP(allowList, ip)
+}
+
+// parseIPNets 解析IP或CIDR列表，单个IP视为掩码全为1的网段
+func parseIPNets(list []string) ([]*net.IPNet, error) {
+	ipNets := make([]*net.IPNet, 0, len(list))
+	for _, entry := range list {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, errors.Errorf("invalid ip: %s", entry)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+
+			ipNets = append(ipNets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid cidr: %s", entry)
+		}
+
+		ipNets = append(ipNets, ipNet)
+	}
+
+	return ipNets, nil
+}
+
+// containsIP 判断IP是否属于任一网段
+func containsIP(ipNets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range ipNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// addrIP 获取网络地址中的IP
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+
+	return hostIP(addr.String())
+}
+
+// hostIP 解析host:port格式地址中的IP
+func hostIP(address string) net.IP {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return net.ParseIP(address)
+	}
+
+	return net.ParseIP(host)
+}
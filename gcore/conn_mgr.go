@@ -1,6 +1,7 @@
 package gcore
 
 import (
+	"context"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -27,6 +28,8 @@ type ConnMgr[T any] struct {
 
 	dispatcher trait.Dispatcher[T]
 
+	taskMgr trait.TaskMgr[T]
+
 	// key: fd, value: Conn
 	connShards *core.KVShards[int32, trait.Connection[T]]
 
@@ -36,17 +39,34 @@ type ConnMgr[T any] struct {
 
 	connNotActiveHook func(conn trait.Connection[T])
 
+	connIdleHook func(conn trait.Connection[T], idleState int)
+
 	keepAliveMgr trait.KeepAliveMgr[T]
 
+	// 收发消息、连接出错与接收连接的观察者
+	observer trait.Observer[T]
+
+	// 时间轮，用于连接的空闲检测、握手超时与定时回调
+	wheel *core.TimingWheel
+
 	connSignalQueue []chan trait.ConnSignal[T]
 
 	wg *sync.WaitGroup
+
+	// 各过载策略的触发次数
+	overloadCounts [constant.OverloadPolicies]atomic.Uint64
+
+	metrics *engineMetrics
+
+	// 连接管理器的上下文，所有连接的上下文都派生自该上下文，连接管理器停止时取消
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 var _ trait.ConnMgr[int] = (*ConnMgr[int])(nil)
 
-// NewConnMgr 新建一个连接管理的实例
-func NewConnMgr[T any](timeout int, eventSize int, taskMgr trait.TaskMgr[T]) (*ConnMgr[T], error) {
+// NewConnMgr 新建一个连接管理的实例，metrics为引擎的指标
+func NewConnMgr[T any](timeout int, eventSize int, taskMgr trait.TaskMgr[T], metrics *engineMetrics) (*ConnMgr[T], error) {
 	// 创建一个epoll句柄
 	epfd, err := syscall.EpollCreate1(0)
 	if err != nil {
@@ -60,6 +80,8 @@ func NewConnMgr[T any](timeout int, eventSize int, taskMgr trait.TaskMgr[T]) (*C
 
 	connShards := core.NewKVShards[int32, trait.Connection[T]](gconf.Config.ConnShardCount())
 
+	ctx, cancel := context.WithCancel(context.Background())
+
 	// 创建一个连接管理器
 	connMgr := &ConnMgr[T]{
 		epfd:            epfd,
@@ -67,12 +89,24 @@ func NewConnMgr[T any](timeout int, eventSize int, taskMgr trait.TaskMgr[T]) (*C
 		events:          make([]syscall.EpollEvent, eventSize),
 		connShards:      connShards,
 		connSignalQueue: connSignalQueues,
+		taskMgr:         taskMgr,
+		metrics:         metrics,
 		wg:              &sync.WaitGroup{},
+		wheel:           core.NewTimingWheel(time.Duration(gconf.Config.TimingWheelTick())*time.Millisecond, gconf.Config.TimingWheelSize()),
+		ctx:             ctx,
+		cancel:          cancel,
 	}
 
+	connMgr.wheel.OnPanic(func(r any, stack []byte) {
+		metrics.panics.Add(1)
+		glog.Errorf("timing wheel callback panic: %v\n%s\n", r, stack)
+	})
+
 	connMgr.dispatcher = NewDispatcher(connMgr, taskMgr)
 
-	connMgr.keepAliveMgr = NewKeepAliveMgr(connMgr, connShards.Shards())
+	connMgr.keepAliveMgr = NewKeepAliveMgr[T](connMgr, taskMgr, metrics)
+
+	connMgr.observer = NewObserver[T](metrics)
 
 	return connMgr, nil
 }
@@ -88,12 +122,8 @@ func (e *ConnMgr[T]) Add(conn trait.Connection[T]) error {
 		return errors.New("online connections limit reached")
 	}
 
-	sock, err := conn.File()
-	if err != nil {
-		glog.Error("get socket file descriptor error:", err)
-		return err
-	}
-	fd := sock.Fd()
+	// 连接ID为注册到epoll的文件描述符
+	fd := conn.ID()
 
 	if _, ok := e.Get(int32(fd)); ok {
 		glog.Error("connection already exists, conn fd:", fd)
@@ -104,7 +134,7 @@ func (e *ConnMgr[T]) Add(conn trait.Connection[T]) error {
 		Events: syscall.EPOLLIN | syscall.EPOLLRDHUP,
 		Fd:     int32(fd),
 	}
-	err = syscall.EpollCtl(e.epfd, syscall.EPOLL_CTL_ADD, int(fd), &event)
+	err := syscall.EpollCtl(e.epfd, syscall.EPOLL_CTL_ADD, int(fd), &event)
 	if err != nil {
 		glog.Error("epoll ctl add error:", err)
 		return err
@@ -114,14 +144,53 @@ func (e *ConnMgr[T]) Add(conn trait.Connection[T]) error {
 
 	e.connShards.Set(int32(fd), conn)
 
+	e.keepAliveMgr.Watch(conn)
+
+	e.handshakeDeadline(conn)
+
+	e.metrics.connsAccepted.Add(1)
+
 	// 通知连接信号处理队列
 	e.PushConnSignal(NewConnSignal[T](conn, constant.ConnStartSignal))
 
 	return nil
 }
 
+// handshakeDeadline 开启握手阶段时，连接在超时时间内未通过握手鉴权则关闭连接
+func (e *ConnMgr[T]) handshakeDeadline(conn trait.Connection[T]) {
+	if len(gconf.Config.HandshakeMsgIDs()) == 0 {
+		return
+	}
+
+	timer := e.wheel.AfterFunc(time.Duration(gconf.Config.HandshakeTimeout())*time.Millisecond, func() {
+		if conn.IsAuthenticated() {
+			return
+		}
+
+		glog.Warnf("connection handshake timeout, conn id: %d\n", conn.ID())
+
+		// 关闭连接会推送连接信号并关闭套接字，可能阻塞，在连接的任务队列中执行，避免阻塞时间轮的协程
+		submitFunc(conn, e.taskMgr, e.metrics, func() {
+			if conn.IsAuthenticated() {
+				return
+			}
+
+			e.CloseConn(conn, constant.CloseByHandshake)
+		})
+	})
+
+	context.AfterFunc(conn.Context(), func() {
+		timer.Stop()
+	})
+}
+
 // Del 在连接管理器中删除连接
 func (e *ConnMgr[T]) Del(fd int32) error {
+	return e.Close(fd, constant.CloseByServer)
+}
+
+// Close 关闭并删除连接，按照关闭原因统计关闭的连接数
+func (e *ConnMgr[T]) Close(fd int32, reason int) error {
 	conn, ok := e.Get(fd)
 	if !ok {
 		glog.Error("call conn stop hook failed, connection not found, conn fd:", fd)
@@ -140,14 +209,39 @@ func (e *ConnMgr[T]) Del(fd int32) error {
 
 	defer e.onlineConns.Add(-1)
 
+	e.connShards.Del(fd)
+
+	// 关闭连接，取消连接的上下文
+	conn.Stop()
+
+	e.metrics.connsClosed.Add(1, closeReasonName(reason))
+
 	// 通知连接信号处理队列
 	e.PushConnSignal(NewConnSignal[T](conn, constant.ConnStopSignal))
 
-	e.connShards.Del(fd)
-
 	return nil
 }
 
+// CloseConn 关闭并删除连接，连接已被删除时不做处理，避免误删复用了文件描述符的新连接，异步关闭连接时使用
+func (e *ConnMgr[T]) CloseConn(conn trait.Connection[T], reason int) error {
+	fd := int32(conn.ID())
+	if cur, ok := e.Get(fd); !ok || cur != conn {
+		return nil
+	}
+
+	return e.Close(fd, reason)
+}
+
+// Conns 遍历连接分片获取所有在线连接的快照
+func (e *ConnMgr[T]) Conns() []trait.Connection[T] {
+	conns := make([]trait.Connection[T], 0, e.OnlineConns())
+	e.connShards.Range(func(_ int32, conn trait.Connection[T]) {
+		conns = append(conns, conn)
+	})
+
+	return conns
+}
+
 // Wait 等待事件发生
 func (e *ConnMgr[T]) Wait() (int, error) {
 	n, err := syscall.EpollWait(e.epfd, e.events, e.timeout)
@@ -165,14 +259,16 @@ func (e *ConnMgr[T]) BatchCommit(n int) {
 		fd := event.Fd
 
 		if event.Events&syscall.EPOLLRDHUP != 0 {
-			// 连接关闭事件处理
-			e.Del(event.Fd)
+			// 连接关闭事件处理，连接不会提交给分发器
+			e.Close(event.Fd, constant.CloseByPeer)
+			e.wg.Done()
 			continue
 		}
 
 		conn, ok := e.Get(fd)
 		if !ok {
 			glog.Error("connection not found, conn fd:", fd)
+			e.wg.Done()
 			continue
 		}
 
@@ -189,19 +285,29 @@ func (e *ConnMgr[T]) Start() {
 
 	e.dispatcher.Start()
 
+	// 时间轮的协程执行连接的健康检查与空闲检测
+	goLabeled(roleKeepAlive, e.wheel.Start)
+
 	e.keepAliveMgr.Start()
 
 	e.StartConnSignalHookWorkers()
 
+	e.observer.Start()
+
 	runtime.LockOSThread()
 	defer runtime.UnlockOSThread()
-	defer syscall.Close(e.epfd)
+
+	labelGoroutine(roleReactor, -1)
 
 	delay := time.Duration(gconf.Config.EpollTimeout()) * time.Millisecond
 
 	for {
 		n, err := e.Wait()
 		if err != nil {
+			if e.ctx.Err() != nil {
+				// 连接管理器已停止
+				return
+			}
 			glog.Error("epoll wait error:", err)
 			continue
 		}
@@ -219,17 +325,29 @@ func (e *ConnMgr[T]) Start() {
 func (e *ConnMgr[T]) Stop() {
 	defer syscall.Close(e.epfd)
 
+	// 取消所有连接的上下文
+	e.cancel()
+
+	e.wheel.Stop()
+
 	n := e.connShards.Count()
 	for conn := range e.connShards.ValuesIter(n) {
 		conn.Stop()
+		e.metrics.connsClosed.Add(1, closeReasonName(constant.CloseByShutdown))
 	}
+
+	// 等待异步执行的观察事件执行完成
+	e.observer.Stop()
 }
 
 // StartConnSignalHookWorkers 启动连接信号钩子消费者工作池
 func (e *ConnMgr[T]) StartConnSignalHookWorkers() {
 	for i := 0; i < len(e.connSignalQueue); i++ {
 		for j := 0; j < gconf.Config.WorkersPerConnSignalQueue(); j++ {
-			go e.StartConnSignalHookWorker(e.connSignalQueue[i])
+			go func(queueID int) {
+				labelGoroutine(roleConnSignal, queueID)
+				e.StartConnSignalHookWorker(e.connSignalQueue[queueID])
+			}(i)
 		}
 	}
 }
@@ -250,6 +368,18 @@ func (m *ConnMgr[T]) StartConnSignalHookWorker(connSignalQueue <-chan trait.Conn
 			if m.connNotActiveHook != nil {
 				m.connNotActiveHook(conn)
 			}
+		case constant.ConnReaderIdleSignal:
+			if m.connIdleHook != nil {
+				m.connIdleHook(conn, constant.ReaderIdle)
+			}
+		case constant.ConnWriterIdleSignal:
+			if m.connIdleHook != nil {
+				m.connIdleHook(conn, constant.WriterIdle)
+			}
+		case constant.ConnAllIdleSignal:
+			if m.connIdleHook != nil {
+				m.connIdleHook(conn, constant.AllIdle)
+			}
 		default:
 			glog.Error("unknown conn signal:", conn.Signal())
 		}
@@ -271,6 +401,11 @@ func (m *ConnMgr[T]) OnConnNotActive(fn func(conn trait.Connection[T])) {
 	m.connNotActiveHook = fn
 }
 
+// OnConnIdle 注册连接读空闲、写空闲或读写空闲触发的钩子回调
+func (m *ConnMgr[T]) OnConnIdle(fn func(conn trait.Connection[T], idleState int)) {
+	m.connIdleHook = fn
+}
+
 // ChooseConnSignalQueue 选择连接信号处理队列
 func (m *ConnMgr[T]) ChooseConnSignalQueue(connID uint64) chan<- trait.ConnSignal[T] {
 	return m.connSignalQueue[connID%uint64(len(m.connSignalQueue))]
@@ -281,6 +416,16 @@ func (m *ConnMgr[T]) PushConnSignal(signal trait.ConnSignal[T]) {
 	m.ChooseConnSignalQueue(signal.ID()) <- signal
 }
 
+// PendingConnSignals 获取所有连接信号处理队列中待处理的信号数量
+func (m *ConnMgr[T]) PendingConnSignals() int {
+	pending := 0
+	for _, connSignalQueue := range m.connSignalQueue {
+		pending += len(connSignalQueue)
+	}
+
+	return pending
+}
+
 // WaitGroup 等待组
 func (m *ConnMgr[T]) WaitGroup() *sync.WaitGroup {
 	return m.wg
@@ -290,3 +435,128 @@ func (m *ConnMgr[T]) WaitGroup() *sync.WaitGroup {
 func (m *ConnMgr[T]) OnlineConns() int32 {
 	return m.onlineConns.Load()
 }
+
+// Context 连接管理器的上下文，连接管理器停止时取消
+func (m *ConnMgr[T]) Context() context.Context {
+	return m.ctx
+}
+
+// Dispatcher 请求分发器
+func (m *ConnMgr[T]) Dispatcher() trait.Dispatcher[T] {
+	return m.dispatcher
+}
+
+// Observer 收发消息、连接出错与接收连接的观察者
+func (m *ConnMgr[T]) Observer() trait.Observer[T] {
+	return m.observer
+}
+
+// TimingWheel 时间轮
+func (m *ConnMgr[T]) TimingWheel() *core.TimingWheel {
+	return m.wheel
+}
+
+// Pause 暂停监听连接的可读事件，连接中的数据不再被读取
+func (m *ConnMgr[T]) Pause(conn trait.Connection[T]) error {
+	return m.modifyEvents(conn, syscall.EPOLLRDHUP)
+}
+
+// Resume 恢复监听连接的可读事件
+func (m *ConnMgr[T]) Resume(conn trait.Connection[T]) error {
+	return m.modifyEvents(conn, syscall.EPOLLIN|syscall.EPOLLRDHUP)
+}
+
+// modifyEvents 修改连接监听的epoll事件
+func (m *ConnMgr[T]) modifyEvents(conn trait.Connection[T], events uint32) error {
+	fd := int32(conn.ID())
+
+	// 连接已被删除时，文件描述符可能已经被新的连接复用
+	if cur, ok := m.Get(fd); !ok || cur != conn {
+		return errors.Errorf("connection not found, conn fd: %d", fd)
+	}
+
+	event := syscall.EpollEvent{
+		Events: events,
+		Fd:     fd,
+	}
+	err := syscall.EpollCtl(m.epfd, syscall.EPOLL_CTL_MOD, int(fd), &event)
+	if err != nil {
+		glog.Error("epoll ctl mod error:", err)
+		return err
+	}
+
+	return nil
+}
+
+// bufferedConn 在用户态缓冲读取数据的连接
+type bufferedConn interface {
+	// commitBuffered 提交缓冲区中剩余的完整消息
+	commitBuffered() error
+}
+
+// Overload 任务队列已满时按照过载策略处理请求，返回true时表示连接已暂停读取
+func (m *ConnMgr[T]) Overload(request trait.Request[T]) (bool, error) {
+	conn := request.Conn()
+	policy := gconf.Config.OverloadPolicy()
+
+	switch policy {
+	case constant.OverloadDrop:
+		m.overloadCounts[policy].Add(1)
+		endDropped(request, "overload")
+
+		err := conn.SendMsg(gconf.Config.OverloadMsgID(), nil)
+		if err != nil {
+			glog.Error("send overload message error:", err)
+		}
+
+		return false, nil
+	case constant.OverloadDisconnect:
+		m.overloadCounts[policy].Add(1)
+		endDropped(request, "overload")
+
+		return false, errors.Errorf("task queue overloaded, conn id: %d", conn.ID())
+	default:
+		m.overloadCounts[constant.OverloadPause].Add(1)
+
+		err := m.Pause(conn)
+		if err != nil {
+			return false, err
+		}
+
+		// 等待请求进入队列后恢复读取，暂停期间不会读取该连接的新请求，保证请求的顺序
+		go func() {
+			m.taskMgr.Submit(request)
+
+			if conn.IsClose() {
+				return
+			}
+
+			// 缓冲在用户态的消息不会触发可读事件，恢复读取前先提交
+			if buffered, ok := conn.(bufferedConn); ok {
+				err := buffered.commitBuffered()
+				if err != nil {
+					if m.CloseConn(conn, closeReason(err)) != nil {
+						glog.Error("del conn error: ", err)
+					}
+					return
+				}
+			}
+
+			err := m.Resume(conn)
+			if err != nil {
+				glog.Error("resume connection error:", err)
+			}
+		}()
+
+		return true, nil
+	}
+}
+
+// OverloadCount 获取过载策略的触发次数
+func (m *ConnMgr[T]) OverloadCount(policy int) uint64 {
+	if policy < 0 || policy >= constant.OverloadPolicies {
+		return 0
+	}
+
+	return m.overloadCounts[policy].Load()
+}
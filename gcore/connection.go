@@ -1,6 +1,7 @@
 package gcore
 
 import (
+	"context"
 	"net"
 	"os"
 	"sync"
@@ -11,6 +12,7 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/pkg/errors"
 	"github.com/zm50/gte/constant"
+	"github.com/zm50/gte/core"
 	"github.com/zm50/gte/gconf"
 	"github.com/zm50/gte/glog"
 	"github.com/zm50/gte/gpack"
@@ -27,38 +29,75 @@ type TCPConnection[T any] struct {
 	// 底层连接的套接字
 	trait.Socket
 
+	// 连接的文件描述符，在连接关闭前保持打开，保证注册到epoll的文件描述符与连接ID一致
+	file *os.File
+
+	// 客户端的真实地址，经过代理转发时为PROXY协议头部中的源地址
+	remoteAddr net.Addr
+
 	state *atomic.Uint32
+	// 最近一次收到数据与发送数据的时间，单位纳秒
+	lastRead  atomic.Int64
+	lastWrite atomic.Int64
+	// 连接建立的时间与收发的字节数
+	startTime time.Time
+	bytesIn   atomic.Uint64
+	bytesOut  atomic.Uint64
 	//防止连接并发写的锁
 	writeLock sync.Mutex
 
 	wg *sync.WaitGroup
 
-	connMgr   trait.ConnMgr[T]
-	taskMgr   trait.TaskMgr[T]
-	taskQueue chan<- trait.Request[T]
+	connMgr trait.ConnMgr[T]
+	taskMgr trait.TaskMgr[T]
+	metrics *engineMetrics
+
+	// 连接的身份标识，通过握手鉴权后设置
+	identity atomic.Pointer[any]
+
+	// 连接的上下文，连接关闭或引擎停止时取消
+	ctx    context.Context
+	cancel context.CancelFunc
 
 	closeOnce sync.Once
+
+	// 等待在任务队列中执行的定时器回调
+	funcs connFuncs
 }
 
 var _ trait.Connection[int] = (*TCPConnection[int])(nil)
 
-// NewTCPConnection 创建一个新的连接对象
-func NewTCPConnection[T any](connID uint64, socket trait.Socket, wg *sync.WaitGroup, connMgr trait.ConnMgr[T], taskMgr trait.TaskMgr[T]) trait.Connection[T] {
+// NewTCPConnection 创建一个新的连接对象，连接ID为文件描述符，remoteAddr为空时使用套接字的对端地址，metrics为引擎的指标
+func NewTCPConnection[T any](file *os.File, socket trait.Socket, remoteAddr net.Addr, wg *sync.WaitGroup, connMgr trait.ConnMgr[T], taskMgr trait.TaskMgr[T], metrics *engineMetrics) trait.Connection[T] {
 	state := &atomic.Uint32{}
 	state.Store(constant.ConnActiveState)
 
+	ctx, cancel := context.WithCancel(connMgr.Context())
+
+	if remoteAddr == nil {
+		remoteAddr = socket.RemoteAddr()
+	}
+
 	conn := &TCPConnection[T]{
-		id:        connID,
-		Socket:    socket,
-		state:     state,
-		writeLock: sync.Mutex{},
-		wg:        wg,
-		connMgr:   connMgr,
-		taskMgr:   taskMgr,
-		taskQueue: taskMgr.ChooseQueue(connID),
-		closeOnce: sync.Once{},
+		id:         uint64(file.Fd()),
+		Socket:     socket,
+		file:       file,
+		remoteAddr: remoteAddr,
+		state:      state,
+		writeLock:  sync.Mutex{},
+		wg:         wg,
+		connMgr:    connMgr,
+		taskMgr:    taskMgr,
+		metrics:    metrics,
+		ctx:        ctx,
+		cancel:     cancel,
+		closeOnce:  sync.Once{},
 	}
 
+	conn.startTime = time.Now()
+	conn.lastRead.Store(conn.startTime.UnixNano())
+	conn.lastWrite.Store(conn.startTime.UnixNano())
+
 	return conn
 }
 
@@ -86,23 +125,37 @@ func (c *TCPConnection[T]) Send(data []byte) error {
 			goto NEXT
 		}
 		glog.Errorf("send data to conn %d err: %v\n", c.id, err)
+		c.connMgr.Observer().ConnError(c, err)
 		return err
 	}
 
+	c.lastWrite.Store(time.Now().UnixNano())
+
+	c.bytesOut.Add(uint64(len(data)))
+	c.metrics.sent(len(data))
+
 	return nil
 }
 
 // SendMsg 发送消息给客户端
 func (c *TCPConnection[T]) SendMsg(msgID uint32, data []byte) error {
 	//封装message消息
-	message := gpack.NewMessage(msgID, data)
+	return c.SendMessage(gpack.NewMessage(msgID, data))
+}
 
+// SendMessage 封包并发送消息给客户端，开启链路上下文时帧头部携带消息的链路上下文
+func (c *TCPConnection[T]) SendMessage(message trait.Message) error {
 	//封包
 	response := gpack.PackTCP(message)
 
 	err := c.Send(response)
+	if err != nil {
+		return err
+	}
 
-	return err
+	c.connMgr.Observer().MessageOut(c, message)
+
+	return nil
 }
 
 // Stop 关闭连接
@@ -110,10 +163,22 @@ func (c *TCPConnection[T]) Stop() {
 	c.SetState(constant.ConnCloseState)
 
 	c.closeOnce.Do(func() {
+		c.cancel()
 		c.Socket.Close()
+		c.file.Close()
 	})
 }
 
+// RemoteAddr 获取客户端的真实地址
+func (c *TCPConnection[T]) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+// File 获取连接的文件描述符
+func (c *TCPConnection[T]) File() (*os.File, error) {
+	return c.file, nil
+}
+
 // BatchCommit 批量提交消息
 func (c *TCPConnection[T]) BatchCommit() error {
 	defer c.wg.Done()
@@ -123,6 +188,8 @@ func (c *TCPConnection[T]) BatchCommit() error {
 	for tryCount > 0 {
 		tryCount--
 
+		readStart := time.Now()
+
 		header, err := gpack.UnpackTCPHeader(c)
 		if err != nil {
 			if err == syscall.EAGAIN {
@@ -139,10 +206,30 @@ func (c *TCPConnection[T]) BatchCommit() error {
 			return errors.WithMessage(err, "unpack tcp body err")
 		}
 
-		// 提交消息，处理数据
-		request := NewRequest(c, msg)
+		size := len(header) + int(msg.DataLen())
+		c.bytesIn.Add(uint64(size))
+		c.metrics.received(size)
+
+		// 收到数据时刷新连接的活跃状态
+		keepAlive(c.state, &c.lastRead)
 
-		c.taskQueue <- request
+		c.connMgr.Observer().MessageIn(c, msg)
+
+		if handleHeartbeat(c, msg) {
+			continue
+		}
+
+		// 提交消息，处理数据
+		request := newRequest(c, msg)
+		request.trace(c.taskMgr.Tracer(), readStart, size)
+
+		if !c.taskMgr.TrySubmit(request) {
+			// 任务队列已满，按照过载策略处理
+			paused, err := c.connMgr.Overload(request)
+			if err != nil || paused {
+				return err
+			}
+		}
 	}
 
 	return nil
@@ -174,6 +261,51 @@ func (c *TCPConnection[T]) SetState(state uint32) {
 	c.state.Store(state)
 }
 
+// LastReadTime 最近一次收到数据的时间
+func (c *TCPConnection[T]) LastReadTime() time.Time {
+	return time.Unix(0, c.lastRead.Load())
+}
+
+// LastWriteTime 最近一次发送数据的时间
+func (c *TCPConnection[T]) LastWriteTime() time.Time {
+	return time.Unix(0, c.lastWrite.Load())
+}
+
+// StartTime 连接建立的时间
+func (c *TCPConnection[T]) StartTime() time.Time {
+	return c.startTime
+}
+
+// BytesIn 连接收到的字节数
+func (c *TCPConnection[T]) BytesIn() uint64 {
+	return c.bytesIn.Load()
+}
+
+// BytesOut 连接发送的字节数
+func (c *TCPConnection[T]) BytesOut() uint64 {
+	return c.bytesOut.Load()
+}
+
+// Probe 发送心跳消息探测连接是否存活，客户端回复任意数据即可刷新连接的活跃状态
+func (c *TCPConnection[T]) Probe() error {
+	return c.SendMsg(gconf.Config.HeartbeatMsgID(), nil)
+}
+
+// AfterFunc 在d之后在连接的任务队列中执行fn，连接关闭后自动取消
+func (c *TCPConnection[T]) AfterFunc(d time.Duration, fn func()) *core.Timer {
+	return connTimer[T](c, c.connMgr, c.taskMgr, c.metrics, d, fn, false)
+}
+
+// Every 每隔d在连接的任务队列中执行一次fn，连接关闭后自动取消
+func (c *TCPConnection[T]) Every(d time.Duration, fn func()) *core.Timer {
+	return connTimer[T](c, c.connMgr, c.taskMgr, c.metrics, d, fn, true)
+}
+
+// pendingFuncs 等待在任务队列中执行的定时器回调
+func (c *TCPConnection[T]) pendingFuncs() *connFuncs {
+	return &c.funcs
+}
+
 // Property 获取连接属性
 func (c *TCPConnection[T]) Property() T {
 	return c.property
@@ -184,6 +316,36 @@ func (c *TCPConnection[T]) SetProperty(property T) {
 	c.property = property
 }
 
+// Context 获取连接的上下文，连接关闭或引擎停止时取消
+func (c *TCPConnection[T]) Context() context.Context {
+	return c.ctx
+}
+
+// Authenticate 标记连接已通过握手鉴权，并记录连接的身份标识
+func (c *TCPConnection[T]) Authenticate(identity any) {
+	c.identity.Store(&identity)
+}
+
+// IsAuthenticated 连接是否已通过握手鉴权
+func (c *TCPConnection[T]) IsAuthenticated() bool {
+	return c.identity.Load() != nil
+}
+
+// Identity 获取连接的身份标识，未通过握手鉴权时返回nil
+func (c *TCPConnection[T]) Identity() any {
+	identity := c.identity.Load()
+	if identity == nil {
+		return nil
+	}
+
+	return *identity
+}
+
+const (
+	// 发送Websocket控制帧的超时时间
+	websocketControlTimeout = time.Second
+)
+
 // Websocket websocket连接
 type WebsocketConnection[T any] struct {
 	// 连接的唯一标识
@@ -193,37 +355,138 @@ type WebsocketConnection[T any] struct {
 
 	*websocket.Conn
 
+	// 底层连接的字节流，非阻塞地读取并解析帧
+	stream *websocketStream
+
+	// 客户端的真实地址，经过代理转发时为转发头部中的地址
+	remoteAddr net.Addr
+
 	//防止连接并发写的锁
 	writeLock sync.Mutex
 
 	wg *sync.WaitGroup
 
 	state *atomic.Uint32
+	// 最近一次收到数据与发送数据的时间，单位纳秒
+	lastRead  atomic.Int64
+	lastWrite atomic.Int64
+	// 连接建立的时间与收发的字节数
+	startTime time.Time
+	bytesIn   atomic.Uint64
+	bytesOut  atomic.Uint64
+
+	connMgr trait.ConnMgr[T]
+	taskMgr trait.TaskMgr[T]
+	metrics *engineMetrics
+
+	// 连接的身份标识，通过握手鉴权后设置
+	identity atomic.Pointer[any]
 
-	connMgr   trait.ConnMgr[T]
-	taskMgr   trait.TaskMgr[T]
-	taskQueue chan<- trait.Request[T]
+	// 连接的上下文，连接关闭或引擎停止时取消
+	ctx    context.Context
+	cancel context.CancelFunc
 
 	closeOnce sync.Once
+
+	// 等待在任务队列中执行的定时器回调
+	funcs connFuncs
 }
 
 var _ trait.Connection[int] = (*WebsocketConnection[int])(nil)
 
-func NewWebsocketConnection[T any](connID uint64, conn *websocket.Conn, wg *sync.WaitGroup, connMgr trait.ConnMgr[T], taskMgr trait.TaskMgr[T]) trait.Connection[T] {
+// NewWebsocketConnection 创建Websocket连接，conn的底层连接需要由Websocket网关的监听器接收，metrics为引擎的指标
+func NewWebsocketConnection[T any](connID uint64, conn *websocket.Conn, remoteAddr net.Addr, wg *sync.WaitGroup, connMgr trait.ConnMgr[T], taskMgr trait.TaskMgr[T], metrics *engineMetrics) trait.Connection[T] {
 	state := &atomic.Uint32{}
 	state.Store(constant.ConnActiveState)
 
-	return &WebsocketConnection[T]{
-		id:        connID,
-		Conn:      conn,
-		writeLock: sync.Mutex{},
-		wg:        wg,
-		state:     state,
-		connMgr:   connMgr,
-		taskMgr:   taskMgr,
-		taskQueue: taskMgr.ChooseQueue(connID),
-		closeOnce: sync.Once{},
+	ctx, cancel := context.WithCancel(connMgr.Context())
+
+	if remoteAddr == nil {
+		remoteAddr = conn.RemoteAddr()
+	}
+
+	w := &WebsocketConnection[T]{
+		id:         connID,
+		Conn:       conn,
+		stream:     conn.NetConn().(*websocketStream),
+		remoteAddr: remoteAddr,
+		writeLock:  sync.Mutex{},
+		wg:         wg,
+		state:      state,
+		connMgr:    connMgr,
+		taskMgr:    taskMgr,
+		metrics:    metrics,
+		ctx:        ctx,
+		cancel:     cancel,
+		closeOnce:  sync.Once{},
+	}
+
+	w.startTime = time.Now()
+	w.lastRead.Store(w.startTime.UnixNano())
+	w.lastWrite.Store(w.startTime.UnixNano())
+
+	// Ping与Pong在解析帧时处理，只收到控制帧时读取不会阻塞，收到Ping或Pong时刷新连接的活跃状态
+	w.stream.scan(w.handlePing, w.handlePong)
+	// 解压后的消息同样不能超过最大长度，超过时websocket库发送1009的关闭帧
+	conn.SetReadLimit(int64(gconf.Config.MaxPacketSize()))
+
+	if gconf.Config.WebsocketCompression() {
+		err := conn.SetCompressionLevel(gconf.Config.WebsocketCompressionLevel())
+		if err != nil {
+			glog.Error("set websocket compression level err:", err)
+		}
+	}
+
+	if gconf.Config.WebsocketPingInterval() > 0 {
+		w.startPing(time.Duration(gconf.Config.WebsocketPingInterval()) * time.Millisecond)
+	}
+
+	return w
+}
+
+// handlePing 回复Pong并刷新连接的活跃状态
+func (w *WebsocketConnection[T]) handlePing(appData string) error {
+	keepAlive(w.state, &w.lastRead)
+
+	err := w.Conn.WriteControl(websocket.PongMessage, []byte(appData), time.Now().Add(websocketControlTimeout))
+	if err == websocket.ErrCloseSent {
+		return nil
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return nil
 	}
+
+	return err
+}
+
+// handlePong 刷新连接的活跃状态
+func (w *WebsocketConnection[T]) handlePong(string) error {
+	keepAlive(w.state, &w.lastRead)
+	return nil
+}
+
+// startPing 按照间隔主动发送Ping，使经过代理的浏览器连接保持活跃，连接关闭后停止发送
+func (w *WebsocketConnection[T]) startPing(interval time.Duration) {
+	var timer *time.Timer
+	timer = time.AfterFunc(interval, func() {
+		if w.ctx.Err() != nil {
+			return
+		}
+
+		err := w.Conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(websocketControlTimeout))
+		if err != nil {
+			glog.Errorf("send websocket ping to conn %d err: %v\n", w.id, err)
+			return
+		}
+
+		timer.Reset(interval)
+	})
+
+	context.AfterFunc(w.ctx, func() {
+		timer.Stop()
+	})
 }
 
 func (w *WebsocketConnection[T]) Read(b []byte) (n int, err error) {
@@ -233,7 +496,7 @@ func (w *WebsocketConnection[T]) Read(b []byte) (n int, err error) {
 	}
 
 	if messageType == websocket.BinaryMessage {
-		w.SetState(constant.ConnActiveState)
+		keepAlive(w.state, &w.lastRead)
 		return 0, errors.New("not support message type")
 	}
 
@@ -258,7 +521,7 @@ func (w *WebsocketConnection[T]) LocalAddr() net.Addr {
 }
 
 func (w *WebsocketConnection[T]) RemoteAddr() net.Addr {
-	return w.Conn.RemoteAddr()
+	return w.remoteAddr
 }
 
 func (w *WebsocketConnection[T]) SetDeadline(t time.Time) error {
@@ -300,33 +563,50 @@ func (w *WebsocketConnection[T]) Send(data []byte) error {
 			goto NEXT
 		}
 		glog.Error("send data to conn %d err: %v", w.id, err)
+		w.connMgr.Observer().ConnError(w, err)
 		return err
 	}
 
+	w.lastWrite.Store(time.Now().UnixNano())
+
+	w.bytesOut.Add(uint64(len(data)))
+	w.metrics.sent(len(data))
+
 	return nil
 }
 
 // SendMsg 发送消息给客户端
 func (w *WebsocketConnection[T]) SendMsg(msgID uint32, data []byte) error {
 	//封装message消息
-	message := gpack.NewMessage(msgID, data)
+	return w.SendMessage(gpack.NewMessage(msgID, data))
+}
 
+// SendMessage 封包并发送消息给客户端，开启链路上下文时帧头部携带消息的链路上下文
+func (w *WebsocketConnection[T]) SendMessage(message trait.Message) error {
 	//封包
 	response := gpack.PackWebsocket(message)
 
 	err := w.Send(response)
+	if err != nil {
+		return err
+	}
 
-	return err
+	w.connMgr.Observer().MessageOut(w, message)
+
+	return nil
 }
 
 func (w *WebsocketConnection[T]) Stop() {
 	w.SetState(constant.ConnCloseState)
 
 	w.closeOnce.Do(func() {
+		w.cancel()
 		w.Conn.Close()
 	})
 }
 
+// BatchCommit 非阻塞地读取套接字中的数据，提交解析出的所有完整消息
+// 读取的数据缓冲在用户态，缓冲的消息不会再触发epoll的可读事件，因此每次读取后提交缓冲区中所有完整的消息
 func (w *WebsocketConnection[T]) BatchCommit() error {
 	defer w.wg.Done()
 
@@ -334,38 +614,93 @@ func (w *WebsocketConnection[T]) BatchCommit() error {
 
 	for tryCount > 0 {
 		tryCount--
-		messageType, data, err := w.Conn.ReadMessage()
+
+		n, err := w.stream.fill()
 		if err != nil {
-			if err == syscall.EAGAIN {
-				// 读超时
-				return nil
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) {
+				// 通知客户端关闭的原因，例如消息超过最大长度时发送1009
+				w.Conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(closeErr.Code, closeErr.Text), time.Now().Add(websocketControlTimeout))
 			}
-			glog.Error("read websocket message err:", err)
 			return err
 		}
 
-		if messageType == websocket.PingMessage {
-			w.SetState(constant.ConnActiveState)
-			continue
+		for w.stream.pending() {
+			paused, err := w.commitMessage(false)
+			if err != nil || paused {
+				return err
+			}
 		}
 
-		if messageType != websocket.BinaryMessage {
-			glog.Errorf("not support message type: %d\n", messageType)
-			return errors.New("not support message type")
+		if n == 0 {
+			// 套接字中没有数据
+			return nil
 		}
+	}
 
-		msg, err := gpack.UnpackWebsocket(data)
+	return nil
+}
+
+// commitBuffered 连接暂停读取期间阻塞地提交缓冲区中剩余的完整消息，恢复读取前调用
+func (w *WebsocketConnection[T]) commitBuffered() error {
+	for w.stream.pending() {
+		_, err := w.commitMessage(true)
 		if err != nil {
-			glog.Error("unpack websocket message err:", err)
 			return err
 		}
+	}
+
+	return nil
+}
 
-		request := NewRequest(w, msg)
+// commitMessage 读取并提交缓冲区中的一条完整消息，block为true时阻塞等待任务队列，否则按照过载策略处理，返回true时表示连接已暂停读取
+func (w *WebsocketConnection[T]) commitMessage(block bool) (bool, error) {
+	readStart := time.Now()
 
-		w.taskQueue <- request
+	messageType, data, err := w.Conn.ReadMessage()
+	w.stream.consume()
+	if err != nil {
+		glog.Error("read websocket message err:", err)
+		return false, err
 	}
 
-	return nil
+	if messageType != websocket.BinaryMessage {
+		glog.Errorf("not support message type: %d\n", messageType)
+		return false, errors.New("not support message type")
+	}
+
+	w.bytesIn.Add(uint64(len(data)))
+	w.metrics.received(len(data))
+
+	msg, err := gpack.UnpackWebsocket(data)
+	if err != nil {
+		glog.Error("unpack websocket message err:", err)
+		return false, err
+	}
+
+	// 收到数据时刷新连接的活跃状态
+	keepAlive(w.state, &w.lastRead)
+
+	w.connMgr.Observer().MessageIn(w, msg)
+
+	if handleHeartbeat(w, msg) {
+		return false, nil
+	}
+
+	request := newRequest(w, msg)
+	request.trace(w.taskMgr.Tracer(), readStart, len(data))
+
+	if block {
+		w.taskMgr.Submit(request)
+		return false, nil
+	}
+
+	if !w.taskMgr.TrySubmit(request) {
+		// 任务队列已满，按照过载策略处理
+		return w.connMgr.Overload(request)
+	}
+
+	return false, nil
 }
 
 // IsActive 连接是否活跃
@@ -394,6 +729,51 @@ func (w *WebsocketConnection[T]) SetState(state uint32) {
 	w.state.Store(state)
 }
 
+// LastReadTime 最近一次收到数据的时间
+func (w *WebsocketConnection[T]) LastReadTime() time.Time {
+	return time.Unix(0, w.lastRead.Load())
+}
+
+// LastWriteTime 最近一次发送数据的时间
+func (w *WebsocketConnection[T]) LastWriteTime() time.Time {
+	return time.Unix(0, w.lastWrite.Load())
+}
+
+// StartTime 连接建立的时间
+func (w *WebsocketConnection[T]) StartTime() time.Time {
+	return w.startTime
+}
+
+// BytesIn 连接收到的字节数
+func (w *WebsocketConnection[T]) BytesIn() uint64 {
+	return w.bytesIn.Load()
+}
+
+// BytesOut 连接发送的字节数
+func (w *WebsocketConnection[T]) BytesOut() uint64 {
+	return w.bytesOut.Load()
+}
+
+// Probe 发送Ping探测连接是否存活，客户端回复Pong即可刷新连接的活跃状态
+func (w *WebsocketConnection[T]) Probe() error {
+	return w.Conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(websocketControlTimeout))
+}
+
+// AfterFunc 在d之后在连接的任务队列中执行fn，连接关闭后自动取消
+func (w *WebsocketConnection[T]) AfterFunc(d time.Duration, fn func()) *core.Timer {
+	return connTimer[T](w, w.connMgr, w.taskMgr, w.metrics, d, fn, false)
+}
+
+// Every 每隔d在连接的任务队列中执行一次fn，连接关闭后自动取消
+func (w *WebsocketConnection[T]) Every(d time.Duration, fn func()) *core.Timer {
+	return connTimer[T](w, w.connMgr, w.taskMgr, w.metrics, d, fn, true)
+}
+
+// pendingFuncs 等待在任务队列中执行的定时器回调
+func (w *WebsocketConnection[T]) pendingFuncs() *connFuncs {
+	return &w.funcs
+}
+
 // Property 获取连接属性
 func (w *WebsocketConnection[T]) Property() T {
 	return w.property
@@ -403,3 +783,179 @@ func (w *WebsocketConnection[T]) Property() T {
 func (w *WebsocketConnection[T]) SetProperty(property T) {
 	w.property = property
 }
+
+// Context 获取连接的上下文，连接关闭或引擎停止时取消
+func (w *WebsocketConnection[T]) Context() context.Context {
+	return w.ctx
+}
+
+// Authenticate 标记连接已通过握手鉴权，并记录连接的身份标识
+func (w *WebsocketConnection[T]) Authenticate(identity any) {
+	w.identity.Store(&identity)
+}
+
+// IsAuthenticated 连接是否已通过握手鉴权
+func (w *WebsocketConnection[T]) IsAuthenticated() bool {
+	return w.identity.Load() != nil
+}
+
+// Identity 获取连接的身份标识，未通过握手鉴权时返回nil
+func (w *WebsocketConnection[T]) Identity() any {
+	identity := w.identity.Load()
+	if identity == nil {
+		return nil
+	}
+
+	return *identity
+}
+
+// keepAlive 收到客户端的数据时记录收到数据的时间，并将连接设置为活跃状态，已关闭的连接保持关闭状态
+func keepAlive(state *atomic.Uint32, lastRead *atomic.Int64) {
+	lastRead.Store(time.Now().UnixNano())
+
+	for {
+		cur := state.Load()
+		if cur == constant.ConnActiveState || cur == constant.ConnCloseState {
+			return
+		}
+
+		if state.CompareAndSwap(cur, constant.ConnActiveState) {
+			return
+		}
+	}
+}
+
+// handleHeartbeat 处理心跳消息，原样回复给客户端，心跳消息不会进入任务处理流
+func handleHeartbeat[T any](conn trait.Connection[T], msg trait.Message) bool {
+	if msg.ID() != gconf.Config.HeartbeatMsgID() {
+		return false
+	}
+
+	err := conn.SendMsg(msg.ID(), msg.Data())
+	if err != nil {
+		glog.Error("reply heartbeat err:", err)
+	}
+
+	return true
+}
+
+// connTimer 创建连接的定时器，到期时将fn提交到连接的任务队列中执行，与连接的请求按顺序处理，连接关闭后定时器自动停止
+func connTimer[T any](conn trait.Connection[T], connMgr trait.ConnMgr[T], taskMgr trait.TaskMgr[T], metrics *engineMetrics, d time.Duration, fn func(), every bool) *core.Timer {
+	var timer atomic.Pointer[core.Timer]
+	stop := context.AfterFunc(conn.Context(), func() {
+		if t := timer.Load(); t != nil {
+			t.Stop()
+		}
+	})
+
+	submit := func() {
+		if !every {
+			// 一次性定时器触发后不再需要随连接关闭而停止
+			stop()
+		}
+
+		if conn.Context().Err() != nil {
+			return
+		}
+
+		submitFunc(conn, taskMgr, metrics, fn)
+	}
+
+	if every {
+		timer.Store(connMgr.TimingWheel().Every(d, submit))
+	} else {
+		timer.Store(connMgr.TimingWheel().AfterFunc(d, submit))
+	}
+
+	return timer.Load()
+}
+
+// connMaxPendingFuncs 连接等待执行的定时器回调的最大数量，超过时丢弃回调
+const connMaxPendingFuncs = 64
+
+// connFuncs 连接等待在任务队列中执行的定时器回调，按照触发的顺序执行，同一时刻最多有一个提交到任务队列的请求
+type connFuncs struct {
+	mu  sync.Mutex
+	fns []func()
+	// 是否有已提交但还未执行完成的请求
+	submitted bool
+}
+
+// funcsConn 可以提交定时器回调的连接
+type funcsConn interface {
+	pendingFuncs() *connFuncs
+}
+
+// run 在任务协程中依次执行等待的回调，执行期间新加入的回调也在本次执行
+func (q *connFuncs) run() {
+	defer func() {
+		if r := recover(); r != nil {
+			// 剩余的回调在下次提交时执行，panic交给任务协程恢复
+			q.mu.Lock()
+			q.submitted = false
+			q.mu.Unlock()
+			panic(r)
+		}
+	}()
+
+	for {
+		q.mu.Lock()
+		if len(q.fns) == 0 {
+			q.submitted = false
+			q.mu.Unlock()
+			return
+		}
+		fn := q.fns[0]
+		q.fns[0] = nil
+		q.fns = q.fns[1:]
+		q.mu.Unlock()
+
+		fn()
+	}
+}
+
+// submitFunc 将fn提交到连接的任务队列中执行，用于时间轮的回调，时间轮的回调不能阻塞
+// 同一连接的回调合并为一个请求按顺序执行，任务队列已满时每个连接最多一个协程等待提交，等待执行的回调过多时丢弃
+func submitFunc[T any](conn trait.Connection[T], taskMgr trait.TaskMgr[T], metrics *engineMetrics, fn func()) {
+	fc, ok := conn.(funcsConn)
+	if !ok {
+		// 未缓冲回调的连接只尝试提交一次
+		if !taskMgr.TrySubmit(NewFuncRequest(conn, fn)) {
+			metrics.timerFuncsDropped.Add(1)
+			glog.Errorf("task queue full, drop timer func of conn %d\n", conn.ID())
+		}
+		return
+	}
+
+	q := fc.pendingFuncs()
+
+	q.mu.Lock()
+	if len(q.fns) >= connMaxPendingFuncs {
+		q.mu.Unlock()
+		metrics.timerFuncsDropped.Add(1)
+		glog.Errorf("too many pending timer funcs, drop timer func of conn %d\n", conn.ID())
+		return
+	}
+	q.fns = append(q.fns, fn)
+	if q.submitted {
+		q.mu.Unlock()
+		return
+	}
+	q.submitted = true
+	q.mu.Unlock()
+
+	request := NewFuncRequest(conn, q.run)
+	queue := taskMgr.ChooseQueue(conn.ID(), constant.TaskPriorityNormal)
+
+	select {
+	case queue <- request:
+	default:
+		go func() {
+			select {
+			case queue <- request:
+			case <-conn.Context().Done():
+				// 连接关闭后不再执行等待的回调
+			}
+		}()
+	}
+}
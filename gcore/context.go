@@ -1,7 +1,13 @@
 package gcore
 
 import (
+	"context"
+	"sync"
+	"time"
+
 	"github.com/zm50/gte/constant"
+	"github.com/zm50/gte/gpack"
+	"github.com/zm50/gte/gtrace"
 	"github.com/zm50/gte/trait"
 )
 
@@ -10,31 +16,166 @@ type Context[T any] struct {
 	trait.Request[T]
 
 	taskIdx int
-	tasks trait.TaskFlow[T]
+	tasks   trait.TaskFlow[T]
+
+	// 当前执行的任务的跨度，未开启链路追踪时为空
+	span *gtrace.Span
+
+	// 任务流执行结束后执行的延迟函数
+	defers []func()
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// 请求级别的键值对，用于在中间件与处理函数之间传递数据
+	keys     map[string]any
+	keysLock sync.RWMutex
 }
 
 var _ trait.Context[int] = (*Context[int])(nil)
 
-// NewContext 创建任务上下文
-func NewContext[T any](req trait.Request[T], handlers trait.TaskFlow[T]) *Context[T] {
+// NewContext 创建任务上下文，上下文在连接关闭或引擎停止时取消，timeout大于0时设置处理超时时间
+func NewContext[T any](req trait.Request[T], handlers trait.TaskFlow[T], timeout time.Duration) *Context[T] {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(req.Conn().Context(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(req.Conn().Context())
+	}
+
+	span := req.Span()
+	if span != nil {
+		ctx = gtrace.ContextWithSpan(ctx, span)
+	}
+
 	return &Context[T]{
-		Request:  req,
+		Request: req,
 
 		taskIdx: -1,
-		tasks: handlers,
+		tasks:   handlers,
+		span:    span,
+
+		ctx:    ctx,
+		cancel: cancel,
 	}
 }
 
-// Next 执行下一个任务
+// Next 执行任务流中剩余的任务，所有任务执行结束或任务流被中止后返回
+// 中间件可以在调用Next前后分别执行逻辑，从而包裹后续的任务
 func (c *Context[T]) Next() {
 	c.taskIdx++
-	if c.taskIdx < c.tasks.Len() {
-		c.tasks.Execute(c.taskIdx, c)
+	for c.taskIdx < c.tasks.Len() {
+		c.execute(c.taskIdx)
 		c.taskIdx++
 	}
 }
 
-// Abort 中止任务流
+// execute 执行任务流中的第idx个任务，开启链路追踪时为任务创建跨度，任务中调用Next执行的任务成为其子跨度
+func (c *Context[T]) execute(idx int) {
+	if c.span == nil {
+		c.tasks.Execute(idx, c)
+		return
+	}
+
+	parent := c.span
+	span := parent.StartChild(spanHandler)
+	span.SetAttribute("handler", TaskFuncName(c.tasks.Funcs()[idx]))
+	span.SetAttribute("index", idx)
+
+	c.span = span
+	defer func() {
+		c.span = parent
+		span.End()
+	}()
+
+	c.tasks.Execute(idx, c)
+}
+
+// Abort 中止任务流，后续的任务不再执行，已经执行的中间件在Next返回后继续执行
 func (c *Context[T]) Abort() {
 	c.taskIdx = constant.AbortIndex
 }
+
+// IsAborted 任务流是否已中止
+func (c *Context[T]) IsAborted() bool {
+	return c.taskIdx >= constant.AbortIndex
+}
+
+// AbortWithMsg 回复消息给客户端并中止任务流
+func (c *Context[T]) AbortWithMsg(msgID uint32, data []byte) error {
+	c.Abort()
+	return c.SendMsg(msgID, data)
+}
+
+// SendMsg 回复消息给客户端，开启链路追踪时记录发送的跨度，并在帧头部携带发送跨度的链路上下文
+func (c *Context[T]) SendMsg(msgID uint32, data []byte) error {
+	message := gpack.NewMessage(msgID, data)
+
+	span := c.span.StartChild(spanSend)
+	if span != nil {
+		span.SetAttribute("msg_id", msgID)
+		span.SetAttribute("bytes", len(data))
+		message.SetTraceContext(span.Context())
+	}
+
+	err := c.Conn().SendMessage(message)
+	if err != nil {
+		span.SetAttribute("error", err.Error())
+	}
+	span.End()
+
+	return err
+}
+
+// Defer 注册延迟函数，任务流执行结束后按照注册的相反顺序执行
+func (c *Context[T]) Defer(fn func()) {
+	c.defers = append(c.defers, fn)
+}
+
+// Run 执行任务流，执行结束后执行注册的延迟函数
+func (c *Context[T]) Run() {
+	defer func() {
+		for i := len(c.defers) - 1; i >= 0; i-- {
+			c.defers[i]()
+		}
+	}()
+
+	c.Next()
+}
+
+// Context 获取请求的标准库上下文，用于感知连接关闭、引擎停止与处理超时
+func (c *Context[T]) Context() context.Context {
+	return c.ctx
+}
+
+// SetContext 替换请求的标准库上下文，新的上下文需要派生自原有的上下文
+func (c *Context[T]) SetContext(ctx context.Context) {
+	c.ctx = ctx
+}
+
+// Set 设置请求级别的键值对
+func (c *Context[T]) Set(key string, value any) {
+	c.keysLock.Lock()
+	defer c.keysLock.Unlock()
+
+	if c.keys == nil {
+		c.keys = make(map[string]any)
+	}
+
+	c.keys[key] = value
+}
+
+// Get 获取请求级别的键值对
+func (c *Context[T]) Get(key string) (any, bool) {
+	c.keysLock.RLock()
+	defer c.keysLock.RUnlock()
+
+	value, ok := c.keys[key]
+	return value, ok
+}
+
+// Release 释放上下文持有的资源，任务流执行结束后调用
+func (c *Context[T]) Release() {
+	c.cancel()
+}
@@ -1,8 +1,12 @@
 package gcore
 
 import (
+	"io"
 	"time"
 
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"github.com/zm50/gte/constant"
 	"github.com/zm50/gte/gconf"
 	"github.com/zm50/gte/glog"
 	"github.com/zm50/gte/trait"
@@ -14,6 +18,7 @@ type Dispatcher[T any] struct {
 	bodyDeadline   time.Time
 
 	connQueue []chan trait.Connection[T]
+	scalers   []*WorkerScaler
 	connMgr   trait.ConnMgr[T]
 	taskMgr   trait.TaskMgr[T]
 }
@@ -27,38 +32,79 @@ func NewDispatcher[T any](connMgr trait.ConnMgr[T], taskMgr trait.TaskMgr[T]) tr
 		connQueue[i] = make(chan trait.Connection[T], gconf.Config.DispatcherQueueLen())
 	}
 
-	return &Dispatcher[T]{
+	d := &Dispatcher[T]{
 		connQueue: connQueue,
+		scalers:   make([]*WorkerScaler, len(connQueue)),
 		connMgr:   connMgr,
 		taskMgr:   taskMgr,
 	}
+
+	for i := 0; i < len(connQueue); i++ {
+		queueID := i
+		d.scalers[i] = NewWorkerScaler(gconf.Config.MinWorkersPerDispatcherQueue(), gconf.Config.MaxWorkersPerDispatcherQueue(),
+			func() int { return len(connQueue[queueID]) }, func() { d.Dispatch(queueID) })
+	}
+
+	return d
 }
 
 // Start 启动请求分发模块
 func (d *Dispatcher[T]) Start() {
 	glog.Info("dispatcher start...")
 
-	for i := 0; i < len(d.connQueue); i++ {
-		for j := 0; j < gconf.Config.DispatcherQueueLen(); j++ {
-			go d.Dispatch(d.connQueue[i])
-		}
+	for _, scaler := range d.scalers {
+		scaler.Start(d.connMgr.Context(), gconf.Config.WorkersPerDispatcherQueue())
 	}
 }
 
-// StartDispatcher 分发连接数据
-func (d *Dispatcher[T]) Dispatch(connQueue chan trait.Connection[T]) {
+// Dispatch 分发连接数据，收到伸缩器的退出通知时返回
+func (d *Dispatcher[T]) Dispatch(queueID int) {
+	connQueue := d.connQueue[queueID]
+	scaler := d.scalers[queueID]
+
+	labelGoroutine(roleDispatcher, queueID)
+
 	// 从conn中读取数据，并将数据提交给taskMgr处理
-	for conn := range connQueue {
+	for {
+		var conn trait.Connection[T]
+		select {
+		case conn = <-connQueue:
+		case <-scaler.Quit():
+			return
+		}
+
+		// 记录连接在队列中的等待时间，等待延迟超过阈值时扩容
+		if commit, ok := conn.(*connCommit[T]); ok {
+			scaler.Begin(time.Since(commit.commitTime))
+			conn = commit.Connection
+		} else {
+			scaler.Begin(0)
+		}
+
 		err := conn.BatchCommit()
 		if err != nil {
 			glog.Error("dispatcher batch commit error: ", err)
-			if d.connMgr.Del(int32(conn.ID())) != nil {
+			reason := closeReason(err)
+			if reason == constant.CloseByError {
+				d.connMgr.Observer().ConnError(conn, err)
+			}
+			if d.connMgr.CloseConn(conn, reason) != nil {
 				glog.Error("del conn error: ", err)
 			}
 		}
+		scaler.Done()
 	}
 }
 
+// closeReason 根据读取数据的错误判断连接的关闭原因
+func closeReason(err error) int {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+		return constant.CloseByPeer
+	}
+
+	return constant.CloseByError
+}
+
 // SetHeaderDeadline 设置header读取超时时间
 func (d *Dispatcher[T]) SetHeaderDeadline(deadline time.Time) {
 	d.headerDeadline = deadline
@@ -75,7 +121,35 @@ func (d *Dispatcher[T]) ChooseQueue(connID uint64) chan<- trait.Connection[T] {
 	return d.connQueue[connID%uint64(len(d.connQueue))]
 }
 
-// Commit 提交连接到队列
+// Workers 获取所有队列的分发工作协程数量
+func (d *Dispatcher[T]) Workers() int {
+	workers := 0
+	for _, scaler := range d.scalers {
+		workers += scaler.Workers()
+	}
+
+	return workers
+}
+
+// PendingConns 获取所有队列中等待读取数据的连接数量
+func (d *Dispatcher[T]) PendingConns() int {
+	pending := 0
+	for _, connQueue := range d.connQueue {
+		pending += len(connQueue)
+	}
+
+	return pending
+}
+
+// Commit 提交连接到队列，记录提交时间用于统计连接在队列中的等待时间
 func (d *Dispatcher[T]) Commit(conn trait.Connection[T]) {
-	d.ChooseQueue(conn.ID()) <- conn
+	d.ChooseQueue(conn.ID()) <- &connCommit[T]{Connection: conn, commitTime: time.Now()}
+}
+
+// connCommit 提交到分发队列的连接
+type connCommit[T any] struct {
+	trait.Connection[T]
+
+	// 连接的提交时间
+	commitTime time.Time
 }
@@ -1,14 +1,22 @@
 package gcore
 
 import (
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"sync"
 
 	"github.com/zm50/gte/constant"
 	"github.com/zm50/gte/gconf"
 	"github.com/zm50/gte/glog"
+	"github.com/zm50/gte/gtrace"
 	"github.com/zm50/gte/trait"
 )
 
+// ErrEngineStarted 引擎已经启动，只能在启动前调用的方法返回该错误
+var ErrEngineStarted = errors.New("engine already started")
+
 // Engine 服务器引擎接口实现
 type Engine[T any] struct {
 	trait.ServerConfig
@@ -16,27 +24,53 @@ type Engine[T any] struct {
 	gateway trait.Gateway[T]
 	connMgr trait.ConnMgr[T]
 	taskMgr trait.TaskMgr[T]
+	limiter trait.ConnLimiter
+
+	// 引擎各模块共享的指标
+	metrics *engineMetrics
+
+	// 管理服务，未配置监听地址时为空
+	admin *AdminServer
+
+	// 引擎是否已经启动，启动后各模块并发读取指标，不能再替换指标注册表
+	started     bool
+	startedLock sync.Mutex
 }
 
 // NewEngine 创建一个新的服务器引擎实例
 func NewEngine[T any]() (*Engine[T], error) {
+	// 每个引擎使用独立的指标注册表
+	metrics := newEngineMetrics(NewMetricsRegistry())
+
 	// 新建任务管理器
-	taskMgr := NewTaskMgr[T]()
+	taskMgr := NewTaskMgr[T](metrics)
 
-	connMgr, err := NewConnMgr(gconf.Config.EpollTimeout(), gconf.Config.EpollEventSize(), taskMgr)
+	connMgr, err := NewConnMgr(gconf.Config.EpollTimeout(), gconf.Config.EpollEventSize(), taskMgr, metrics)
 	if err != nil {
 		glog.Error("NewConnMgr error:", err)
 		return nil, err
 	}
 
+	limiter, err := NewConnLimiter(metrics)
+	if err != nil {
+		glog.Error("NewConnLimiter error:", err)
+		return nil, err
+	}
+
+	resolver, err := NewRealAddrResolver()
+	if err != nil {
+		glog.Error("NewRealAddrResolver error:", err)
+		return nil, err
+	}
+
 	var gateway trait.Gateway[T]
 	switch gconf.Config.NetworkMode() {
 	case constant.TCPNetowrkMode:
-		gateway = NewTCPGateway(connMgr, taskMgr)
+		gateway = NewTCPGateway(connMgr, taskMgr, limiter, resolver, metrics)
 	case constant.WebsocketNetworkMode:
-		gateway = NewWebsocketGateway(connMgr, taskMgr)
+		gateway = NewWebsocketGateway(connMgr, taskMgr, limiter, resolver, metrics)
 	default:
-		gateway = NewTCPGateway(connMgr, taskMgr)
+		gateway = NewTCPGateway(connMgr, taskMgr, limiter, resolver, metrics)
 	}
 
 	engine := &Engine[T]{
@@ -44,6 +78,20 @@ func NewEngine[T any]() (*Engine[T], error) {
 		gateway:      gateway,
 		connMgr:      connMgr,
 		taskMgr:      taskMgr,
+		limiter:      limiter,
+		metrics:      metrics,
+	}
+
+	engine.registerCollectors(metrics.registry)
+
+	if gconf.Config.AdminAddr() != "" {
+		engine.admin = NewAdminServer(gconf.Config.AdminAddr(), gconf.Config.AdminToken())
+		engine.admin.HandleFunc(gconf.Config.MetricsPath(), engine.serveMetrics)
+		engine.registerAdminAPI(engine.admin)
+
+		if gconf.Config.AdminPprof() {
+			registerPprof(engine.admin)
+		}
 	}
 
 	return engine, nil
@@ -51,14 +99,32 @@ func NewEngine[T any]() (*Engine[T], error) {
 
 // Run 启动服务器引擎
 func (e *Engine[T]) Run() error {
+	e.startedLock.Lock()
+	e.started = true
+	e.startedLock.Unlock()
+
 	glog.Init()
 
 	fmt.Print(constant.Logo)
 	glog.Infof("Server listening on %s:%d\n", gconf.Config.ListenIP(), gconf.Config.ListenPort())
 
-	e.taskMgr.Start()
+	for _, route := range e.Routes() {
+		glog.Infof("route %d name: %q group: %s handlers: %d %v\n", route.ID(), route.Name(), route.Group(), route.Flow().Len(), route.Handlers())
+	}
+
+	// 任务管理器随连接管理器的上下文停止伸缩
+	e.taskMgr.Start(e.connMgr.Context())
 	go e.connMgr.Start()
 
+	if e.admin != nil {
+		go func() {
+			err := e.admin.ListenAndServe()
+			if err != nil {
+				glog.Error("admin server error:", err)
+			}
+		}()
+	}
+
 	err := e.gateway.ListenAndServe()
 	if err != nil {
 		glog.Error("ListenAndServe error:", err)
@@ -68,16 +134,73 @@ func (e *Engine[T]) Run() error {
 	return nil
 }
 
-// Regist 注册任务处理逻辑
-func (e *Engine[T]) Regist(id uint32, flow ...TaskFunc[T]) {
+// Stop 停止服务器引擎，停止接收新连接并关闭所有连接，所有请求的上下文随之取消
+func (e *Engine[T]) Stop() error {
+	err := e.gateway.Stop()
+	if err != nil {
+		glog.Error("gateway stop error:", err)
+	}
+
+	e.connMgr.Stop()
+
+	if e.admin != nil {
+		adminErr := e.admin.Stop()
+		if adminErr != nil {
+			glog.Error("admin server stop error:", adminErr)
+		}
+	}
+
+	return err
+}
+
+// Regist 注册任务处理逻辑，返回的路由可用于设置处理超时时间等路由配置
+func (e *Engine[T]) Regist(id uint32, flow ...TaskFunc[T]) trait.Route[T] {
+	fw := make([]trait.TaskFunc[T], 0, len(flow))
 	for _, fn := range flow {
-		e.taskMgr.Regist(id, fn)
+		fw = append(fw, fn)
 	}
+
+	return e.taskMgr.Regist(id, fw...)
 }
 
 // RegistFlow 注册任务处理流
-func (e *Engine[T]) RegistFlow(id uint32, flow trait.TaskFlow[T]) {
-	e.taskMgr.RegistFlow(id, flow)
+func (e *Engine[T]) RegistFlow(id uint32, flow trait.TaskFlow[T]) trait.Route[T] {
+	return e.taskMgr.RegistFlow(id, flow)
+}
+
+// Replace 在运行期间原子地替换任务处理逻辑，正在处理的请求继续使用旧的任务处理流
+func (e *Engine[T]) Replace(id uint32, flow ...TaskFunc[T]) trait.Route[T] {
+	fw := make([]trait.TaskFunc[T], 0, len(flow))
+	for _, fn := range flow {
+		fw = append(fw, fn)
+	}
+
+	return e.taskMgr.Replace(id, fw...)
+}
+
+// Unregist 注销任务处理逻辑，运行期间可安全调用
+func (e *Engine[T]) Unregist(id uint32) bool {
+	return e.taskMgr.Unregist(id)
+}
+
+// Enable 启用路由
+func (e *Engine[T]) Enable(id uint32) bool {
+	return e.taskMgr.Enable(id)
+}
+
+// Disable 停用路由，停用期间丢弃对应的请求，可用于功能开关
+func (e *Engine[T]) Disable(id uint32) bool {
+	return e.taskMgr.Disable(id)
+}
+
+// Route 获取路由信息
+func (e *Engine[T]) Route(id uint32) (trait.Route[T], bool) {
+	return e.taskMgr.Route(id)
+}
+
+// Routes 获取所有已注册的路由信息，按照消息ID升序排列
+func (e *Engine[T]) Routes() []trait.Route[T] {
+	return e.taskMgr.Routes()
 }
 
 // TaskFlow 获取任务处理流
@@ -95,6 +218,16 @@ func (e *Engine[T]) Group(flow ...TaskFunc[T]) trait.RouterGroup[T] {
 	return e.taskMgr.Group(fw...)
 }
 
+// NamedGroup 具名路由分组，路由信息中记录路由组的路径
+func (e *Engine[T]) NamedGroup(name string, flow ...TaskFunc[T]) trait.RouterGroup[T] {
+	fw := make([]trait.TaskFunc[T], 0, len(flow))
+	for _, fn := range flow {
+		fw = append(fw, fn)
+	}
+
+	return e.taskMgr.NamedGroup(name, fw...)
+}
+
 // Use 注册插件
 func (e *Engine[T]) Use(flow ...TaskFunc[T]) {
 	for _, fn := range flow {
@@ -102,6 +235,115 @@ func (e *Engine[T]) Use(flow ...TaskFunc[T]) {
 	}
 }
 
+// PendingTasks 获取指定优先级的任务队列中待处理的请求数量
+func (e *Engine[T]) PendingTasks(priority int) int {
+	return e.taskMgr.PendingTasks(priority)
+}
+
+// TaskWorkers 获取当前的任务消费者数量
+func (e *Engine[T]) TaskWorkers() int {
+	return e.taskMgr.Workers()
+}
+
+// DispatcherWorkers 获取当前的请求分发工作协程数量
+func (e *Engine[T]) DispatcherWorkers() int {
+	return e.connMgr.Dispatcher().Workers()
+}
+
+// OverloadCount 获取过载策略的触发次数，可用于过载告警
+func (e *Engine[T]) OverloadCount(policy int) uint64 {
+	return e.connMgr.OverloadCount(policy)
+}
+
+// SetAllowList 重新加载连接地址白名单，列表项为IP或CIDR，为空时不限制
+func (e *Engine[T]) SetAllowList(list []string) error {
+	return e.limiter.SetAllowList(list)
+}
+
+// SetDenyList 重新加载连接地址黑名单，列表项为IP或CIDR
+func (e *Engine[T]) SetDenyList(list []string) error {
+	return e.limiter.SetDenyList(list)
+}
+
+// RejectCount 获取拒绝原因对应的连接拒绝次数
+func (e *Engine[T]) RejectCount(reason int) uint64 {
+	return e.limiter.RejectCount(reason)
+}
+
+// SetMetricsRegistry 替换引擎的指标注册表，可以接入自定义的监控系统，只影响当前引擎，替换前已记录的指标不会迁移
+// 需要在Run之前调用，引擎启动后调用时返回ErrEngineStarted
+func (e *Engine[T]) SetMetricsRegistry(registry trait.MetricsRegistry) error {
+	e.startedLock.Lock()
+	defer e.startedLock.Unlock()
+
+	if e.started {
+		return ErrEngineStarted
+	}
+
+	e.metrics.reset(registry)
+	e.registerCollectors(registry)
+
+	return nil
+}
+
+// MetricsRegistry 获取引擎当前的指标注册表，可用于注册业务指标
+func (e *Engine[T]) MetricsRegistry() trait.MetricsRegistry {
+	return e.metrics.registry
+}
+
+// SetTracer 设置链路追踪器，记录请求读取、排队、各任务执行与回复的跨度，导出跨度失败时记录错误日志，为nil时关闭链路追踪
+func (e *Engine[T]) SetTracer(t *gtrace.Tracer) {
+	if t != nil {
+		t.OnError(func(err error) {
+			glog.Error("export span err:", err)
+		})
+	}
+
+	e.taskMgr.SetTracer(t)
+}
+
+// Tracer 获取引擎当前的链路追踪器，未开启链路追踪时返回nil
+func (e *Engine[T]) Tracer() *gtrace.Tracer {
+	return e.taskMgr.Tracer()
+}
+
+// OnSlowRequest 注册慢请求的回调函数，处理函数执行或请求排队的时间超过路由或全局配置的阈值时在独立的协程中调用，可用于接入告警
+func (e *Engine[T]) OnSlowRequest(fn func(event trait.SlowRequest[T])) {
+	e.taskMgr.OnSlowRequest(fn)
+}
+
+// OnMessageIn 注册收到客户端消息的观察函数，心跳消息同样会通知，可用于审计与抓包，需要在Run之前调用
+func (e *Engine[T]) OnMessageIn(fn func(conn trait.Connection[T], msg trait.Message)) {
+	e.connMgr.Observer().OnMessageIn(fn)
+}
+
+// OnMessageOut 注册发送消息给客户端的观察函数，消息发送成功后通知，需要在Run之前调用
+func (e *Engine[T]) OnMessageOut(fn func(conn trait.Connection[T], msg trait.Message)) {
+	e.connMgr.Observer().OnMessageOut(fn)
+}
+
+// OnConnError 注册连接读写出错的观察函数，客户端正常关闭连接时不通知，需要在Run之前调用
+func (e *Engine[T]) OnConnError(fn func(conn trait.Connection[T], err error)) {
+	e.connMgr.Observer().OnConnError(fn)
+}
+
+// OnAccept 注册接收连接的观察函数，连接通过准入限制后在网关的协程中同步调用，返回false时拒绝连接，需要在Run之前调用
+func (e *Engine[T]) OnAccept(fn func(conn net.Conn) bool) {
+	e.connMgr.Observer().OnAccept(fn)
+}
+
+// ObserverDropped 异步执行观察函数时观察事件因队列已满被丢弃的次数，event取值为constant中定义的观察事件
+func (e *Engine[T]) ObserverDropped(event int) uint64 {
+	return e.connMgr.Observer().Dropped(event)
+}
+
+// OnUpgrade 注册Websocket升级连接前回调的钩子函数，返回错误时拒绝升级，返回值作为连接属性，仅在Websocket网络模式下生效
+func (e *Engine[T]) OnUpgrade(fn func(r *http.Request) (T, error)) {
+	if gateway, ok := e.gateway.(trait.WebsocketGateway[T]); ok {
+		gateway.OnUpgrade(fn)
+	}
+}
+
 // OnConnStart 注册连接建立的回调函数
 func (e *Engine[T]) OnConnStart(fn func(conn trait.Connection[T])) {
 	e.connMgr.OnConnStart(fn)
@@ -116,3 +358,8 @@ func (e *Engine[T]) OnConnStop(fn func(conn trait.Connection[T])) {
 func (e *Engine[T]) OnConnNotActive(fn func(conn trait.Connection[T])) {
 	e.connMgr.OnConnNotActive(fn)
 }
+
+// OnConnIdle 注册连接空闲的回调函数，idleState为constant.ReaderIdle、constant.WriterIdle或constant.AllIdle
+func (e *Engine[T]) OnConnIdle(fn func(conn trait.Connection[T], idleState int)) {
+	e.connMgr.OnConnIdle(fn)
+}
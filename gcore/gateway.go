@@ -1,21 +1,28 @@
 package gcore
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
-	"reflect"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
-	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/zm50/gte/constant"
 	"github.com/zm50/gte/gconf"
 	"github.com/zm50/gte/glog"
 	"github.com/zm50/gte/trait"
 )
 
-const (
-	connManyWait = 3 * time.Second
+var (
+	// ErrConnRejected 连接未通过准入限制，已被关闭
+	ErrConnRejected = errors.New("connection rejected")
 )
 
 // TCPGateway 网关模块，处理客户端TCP连接建立与注册
@@ -25,31 +32,42 @@ type TCPGateway[T any] struct {
 
 	listener *net.TCPListener
 
-	connMgr trait.ConnMgr[T]
-	taskMgr trait.TaskMgr[T]
+	connMgr  trait.ConnMgr[T]
+	taskMgr  trait.TaskMgr[T]
+	limiter  trait.ConnLimiter
+	resolver *RealAddrResolver
+	metrics  *engineMetrics
+
+	// 正在读取PROXY协议头部的连接，限制读取头部的协程数量
+	headerReads chan struct{}
 }
 
 var _ trait.Gateway[any] = (*TCPGateway[any])(nil)
 
-// NewTCPGateway 创建网关实例
-func NewTCPGateway[T any](connMgr trait.ConnMgr[T], taskMgr trait.TaskMgr[T]) trait.Gateway[T] {
+// NewTCPGateway 创建网关实例，metrics为引擎的指标
+func NewTCPGateway[T any](connMgr trait.ConnMgr[T], taskMgr trait.TaskMgr[T], limiter trait.ConnLimiter, resolver *RealAddrResolver, metrics *engineMetrics) trait.Gateway[T] {
 	address := net.TCPAddr{
 		IP:   net.ParseIP(gconf.Config.ListenIP()),
 		Port: gconf.Config.ListenPort(),
 	}
 
 	return &TCPGateway[T]{
-		address: address,
-		version: gconf.Config.NetworkVersion(),
-		connMgr: connMgr,
-		taskMgr: taskMgr,
+		address:  address,
+		version:  gconf.Config.NetworkVersion(),
+		connMgr:  connMgr,
+		taskMgr:  taskMgr,
+		limiter:  limiter,
+		resolver: resolver,
+		metrics:  metrics,
+
+		headerReads: make(chan struct{}, max(gconf.Config.MaxProxyHeaderReads(), 1)),
 	}
 }
 
 // ListenAndServe 监听TCP连接并接收客户端连接，连接建立后注册到连接管理器
 func (g *TCPGateway[T]) ListenAndServe() error {
 	var err error
-	g.listener, err = net.ListenTCP(g.version, &g.address)
+	g.listener, err = listen(g.version, g.address.String())
 	if err != nil {
 		return err
 	}
@@ -57,23 +75,87 @@ func (g *TCPGateway[T]) ListenAndServe() error {
 	glog.Info("tcp gateway start...")
 
 	for {
-		if g.connMgr.OnlineConns() >= gconf.Config.MaxConns() {
-			glog.Error("too many connections")
-			time.Sleep(connManyWait)
+		conn, err := g.listener.AcceptTCP()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				// 网关已停止
+				return nil
+			}
+			glog.Error("AcceptTCP error:", err)
 			continue
 		}
 
-		conn, err := g.Accept()
-		if err != nil {
-			glog.Error("Accept error:", err)
+		if !g.admit(conn) {
+			continue
+		}
+
+		if gconf.Config.ProxyProtocol() {
+			// 读取PROXY协议头部需要等待代理发送数据，避免阻塞接收其他连接，同时读取头部的连接数达到上限时拒绝连接
+			select {
+			case g.headerReads <- struct{}{}:
+			default:
+				g.limiter.Reject(constant.RejectProxyHeaderReads)
+				glog.Warnf("reject connection from %s: too many pending proxy headers\n", conn.RemoteAddr())
+				conn.Close()
+				continue
+			}
+
+			go func() {
+				defer func() { <-g.headerReads }()
+				g.serve(conn)
+			}()
 			continue
 		}
 
-		g.connMgr.Add(conn)
+		g.serve(conn)
+	}
+}
+
+// admit 在读取客户端地址前检查在线连接数与接收连接的速率，未通过时关闭连接
+func (g *TCPGateway[T]) admit(conn *net.TCPConn) bool {
+	if g.connMgr.OnlineConns() >= gconf.Config.MaxConns() {
+		g.limiter.Reject(constant.RejectMaxConns)
+		glog.Warnf("reject connection from %s: too many connections\n", conn.RemoteAddr())
+		conn.Close()
+		return false
+	}
+
+	if !g.limiter.AllowAccept() {
+		glog.Warnf("reject connection from %s: accept rate exceeded\n", conn.RemoteAddr())
+		conn.Close()
+		return false
+	}
+
+	return true
+}
+
+// serve 创建连接并注册到连接管理器
+func (g *TCPGateway[T]) serve(conn *net.TCPConn) {
+	connection, err := g.newConnection(conn)
+	if err != nil {
+		if !errors.Is(err, ErrConnRejected) {
+			glog.Error("new connection error:", err)
+		}
+		return
+	}
+
+	err = g.connMgr.Add(connection)
+	if err != nil {
+		glog.Error("add connection error:", err)
+		connection.Stop()
+	}
+}
+
+// Stop 停止监听TCP连接
+func (g *TCPGateway[T]) Stop() error {
+	if g.listener == nil {
+		return nil
 	}
+
+	return g.listener.Close()
 }
 
-// Accept 接收客户端连接
+// Accept 接收客户端连接，未通过准入限制的连接直接关闭，避免连接堆积在监听队列中
 func (g *TCPGateway[T]) Accept() (trait.Connection[T], error) {
 	conn, err := g.listener.AcceptTCP()
 	if err != nil {
@@ -81,105 +163,367 @@ func (g *TCPGateway[T]) Accept() (trait.Connection[T], error) {
 		return nil, err
 	}
 
+	if !g.admit(conn) {
+		return nil, ErrConnRejected
+	}
+
+	return g.newConnection(conn)
+}
+
+// newConnection 基于客户端的真实地址检查准入限制，通过后创建连接，调用前需要通过admit的检查
+func (g *TCPGateway[T]) newConnection(conn *net.TCPConn) (trait.Connection[T], error) {
+	remoteAddr, err := g.resolver.ConnAddr(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	ip := addrIP(remoteAddr)
+
+	ok, reason := g.limiter.Acquire(ip)
+	if !ok {
+		glog.Warnf("reject connection from %s, reason: %d\n", ip, reason)
+		conn.Close()
+		return nil, ErrConnRejected
+	}
+
+	if !g.connMgr.Observer().Accept(conn) {
+		g.limiter.Reject(constant.RejectObserver)
+		glog.Warnf("reject connection from %s by observer\n", ip)
+		g.limiter.Release(ip)
+		conn.Close()
+		return nil, ErrConnRejected
+	}
+
+	err = applyConnOptions(conn)
+	if err != nil {
+		glog.Error("Failed to set socket options:", err)
+		g.limiter.Release(ip)
+		conn.Close()
+		return nil, err
+	}
+
 	file, err := conn.File()
 	if err != nil {
 		glog.Error("Failed to get file descriptor:", err)
+		g.limiter.Release(ip)
+		conn.Close()
 		return nil, err
 	}
 
 	err = syscall.SetNonblock(int(file.Fd()), true)
 	if err != nil {
 		glog.Error("Failed to set non-blocking:", err)
+		g.limiter.Release(ip)
+		file.Close()
+		conn.Close()
 		return nil, err
 	}
 
-	connection := NewTCPConnection(uint64(file.Fd()), conn, g.connMgr.WaitGroup(), g.connMgr, g.taskMgr)
+	connection := NewTCPConnection(file, conn, remoteAddr, g.connMgr.WaitGroup(), g.connMgr, g.taskMgr, g.metrics)
+
+	// 连接关闭后释放占用的连接数
+	context.AfterFunc(connection.Context(), func() {
+		g.limiter.Release(ip)
+	})
 
 	return connection, nil
 }
 
+// websocketConn 升级完成的Websocket连接
+type websocketConn[T any] struct {
+	conn       *websocket.Conn
+	remoteAddr net.Addr
+	// 升级钩子函数返回的连接属性
+	property T
+}
+
 // WebsocketGateway 网关模块，处理客户端Websocket连接建立与注册
 type WebsocketGateway[T any] struct {
 	upgrader *websocket.Upgrader
 	address  string
-	connCh   chan *websocket.Conn
-
-	connMgr trait.ConnMgr[T]
-	taskMgr trait.TaskMgr[T]
+	connCh   chan websocketConn[T]
+	mux      *http.ServeMux
+	// 由ListenAndServe保存，Stop可能在其他协程中读取
+	server   atomic.Pointer[http.Server]
+	done     chan struct{}
+	stopOnce sync.Once
+
+	// 升级连接前回调的钩子函数，返回错误时拒绝升级，返回值作为连接属性
+	onUpgrade func(r *http.Request) (T, error)
+
+	connMgr  trait.ConnMgr[T]
+	taskMgr  trait.TaskMgr[T]
+	limiter  trait.ConnLimiter
+	resolver *RealAddrResolver
+	metrics  *engineMetrics
 }
 
-var _ trait.Gateway[any] = (*WebsocketGateway[any])(nil)
+var _ trait.WebsocketGateway[any] = (*WebsocketGateway[any])(nil)
 
-func NewWebsocketGateway[T any](connMgr trait.ConnMgr[T], taskMgr trait.TaskMgr[T]) trait.Gateway[T] {
+// NewWebsocketGateway 创建Websocket网关实例，metrics为引擎的指标
+func NewWebsocketGateway[T any](connMgr trait.ConnMgr[T], taskMgr trait.TaskMgr[T], limiter trait.ConnLimiter, resolver *RealAddrResolver, metrics *engineMetrics) trait.Gateway[T] {
 	return &WebsocketGateway[T]{
 		upgrader: &websocket.Upgrader{
-			ReadBufferSize:  1024,
-			WriteBufferSize: 1024,
-			CheckOrigin: func(r *http.Request) bool {
-				return true
-			},
+			ReadBufferSize:    1024,
+			WriteBufferSize:   1024,
+			CheckOrigin:       checkOrigin,
+			Subprotocols:      gconf.Config.WebsocketSubprotocols(),
+			EnableCompression: gconf.Config.WebsocketCompression(),
 		},
-		address: fmt.Sprintf("%s:%d", gconf.Config.ListenIP(), gconf.Config.ListenPort()),
-		connCh:  make(chan *websocket.Conn, 1024),
-		connMgr: connMgr,
-		taskMgr: taskMgr,
+		address:  fmt.Sprintf("%s:%d", gconf.Config.ListenIP(), gconf.Config.ListenPort()),
+		connCh:   make(chan websocketConn[T], 1024),
+		mux:      http.NewServeMux(),
+		done:     make(chan struct{}),
+		connMgr:  connMgr,
+		taskMgr:  taskMgr,
+		limiter:  limiter,
+		resolver: resolver,
+		metrics:  metrics,
 	}
 }
 
-func (g *WebsocketGateway[T]) ListenAndServe() error {
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		if g.connMgr.OnlineConns() >= gconf.Config.MaxConns() {
-			glog.Error("too many connections")
-			return
-		}
+// OnUpgrade 注册升级连接前回调的钩子函数，可用于基于请求头或查询参数鉴权，返回错误时拒绝升级，返回值作为连接属性
+func (g *WebsocketGateway[T]) OnUpgrade(fn func(r *http.Request) (T, error)) {
+	g.onUpgrade = fn
+}
+
+// ServeHTTP 处理Websocket升级请求，未通过准入限制或升级钩子函数的请求被拒绝
+func (g *WebsocketGateway[T]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	remoteAddr := g.resolver.RequestAddr(r)
+	ip := addrIP(remoteAddr)
+
+	if g.connMgr.OnlineConns() >= gconf.Config.MaxConns() {
+		g.limiter.Reject(constant.RejectMaxConns)
+		g.reject(w, ip, constant.RejectMaxConns)
+		return
+	}
+
+	if !g.limiter.AllowAccept() {
+		g.reject(w, ip, constant.RejectAcceptRate)
+		return
+	}
+
+	ok, reason := g.limiter.Acquire(ip)
+	if !ok {
+		g.reject(w, ip, reason)
+		return
+	}
 
-		conn, err := g.upgrader.Upgrade(w, r, nil)
+	var property T
+	if g.onUpgrade != nil {
+		var err error
+		property, err = g.onUpgrade(r)
 		if err != nil {
-			glog.Error("websocket upgrade error:", err)
+			glog.Warnf("websocket upgrade from %s rejected by hook: %v\n", ip, err)
+			g.limiter.Release(ip)
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
 			return
 		}
+	}
 
-		g.connCh <- conn
-	})
+	conn, err := g.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		glog.Error("websocket upgrade error:", err)
+		g.limiter.Release(ip)
+		return
+	}
+
+	select {
+	case g.connCh <- websocketConn[T]{conn: conn, remoteAddr: remoteAddr, property: property}:
+	case <-g.done:
+		// 网关已停止，不再接收连接，关闭已升级的连接并释放占用的连接数
+		g.limiter.Release(ip)
+		conn.Close()
+	}
+}
+
+func (g *WebsocketGateway[T]) ListenAndServe() error {
+	for _, pattern := range gconf.Config.WebsocketPaths() {
+		g.mux.Handle(pattern, g)
+	}
 
 	go func() {
 		for {
 			conn, err := g.Accept()
 			if err != nil {
-				glog.Error("Accept websocket error:", err)
+				if errors.Is(err, net.ErrClosed) {
+					// 网关已停止
+					return
+				}
+				if !errors.Is(err, ErrConnRejected) {
+					glog.Error("Accept websocket error:", err)
+				}
 				continue
 			}
 
-			g.connMgr.Add(conn)
+			err = g.connMgr.Add(conn)
+			if err != nil {
+				glog.Error("add connection error:", err)
+				conn.Stop()
+			}
 		}
 	}()
 
 	glog.Info("websocket gateway start...")
 
-	err := http.ListenAndServe(g.address, nil)
+	ln, err := listen(gconf.Config.NetworkVersion(), g.address)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Addr: g.address, Handler: g.mux, ConnState: connState}
+	g.server.Store(server)
+
+	select {
+	case <-g.done:
+		// 网关已停止，Stop可能在保存服务前读取，不会关闭该服务
+		ln.Close()
+		return nil
+	default:
+	}
+
+	err = server.Serve(&websocketListener{TCPListener: ln})
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
 
 	return err
 }
 
 func (g *WebsocketGateway[T]) Accept() (trait.Connection[T], error) {
-	conn := <-g.connCh
+	var wsConn websocketConn[T]
+	select {
+	case wsConn = <-g.connCh:
+	case <-g.done:
+		return nil, net.ErrClosed
+	}
 
-	fd := g.websocketFD(conn)
+	conn, remoteAddr := wsConn.conn, wsConn.remoteAddr
+	ip := addrIP(remoteAddr)
+
+	// 连接需要由网关的监听器接收，保证读取消息时不会阻塞
+	if _, ok := conn.NetConn().(*websocketStream); !ok {
+		g.limiter.Release(ip)
+		conn.Close()
+		return nil, fmt.Errorf("websocket underlying conn %T is not accepted by the gateway listener", conn.NetConn())
+	}
 
-	err := syscall.SetNonblock(fd, true)
+	fd, err := websocketFD(conn.NetConn())
 	if err != nil {
-		glog.Error("Failed to set non-blocking:", err)
+		glog.Error("Failed to get websocket file descriptor:", err)
+		g.limiter.Release(ip)
+		conn.Close()
 		return nil, err
 	}
 
-	connection := NewWebsocketConnection(uint64(fd), conn, g.connMgr.WaitGroup(), g.connMgr, g.taskMgr)
+	if !g.connMgr.Observer().Accept(conn.NetConn()) {
+		g.limiter.Reject(constant.RejectObserver)
+		glog.Warnf("reject websocket connection from %s by observer\n", ip)
+		g.limiter.Release(ip)
+		conn.Close()
+		return nil, ErrConnRejected
+	}
+
+	connection := NewWebsocketConnection(uint64(fd), conn, remoteAddr, g.connMgr.WaitGroup(), g.connMgr, g.taskMgr, g.metrics)
+	connection.SetProperty(wsConn.property)
+
+	// 连接关闭后释放占用的连接数
+	context.AfterFunc(connection.Context(), func() {
+		g.limiter.Release(ip)
+	})
 
 	return connection, nil
 }
 
-func (g *WebsocketGateway[T]) websocketFD(conn *websocket.Conn) int {
-	tcpConn := reflect.Indirect(reflect.ValueOf(conn)).FieldByName("conn")
-	fdVal := tcpConn.FieldByName("fd")
-	pfdVal := reflect.Indirect(fdVal).FieldByName("pfd")
-	return int(pfdVal.FieldByName("Sysfd").Int())
+// reject 拒绝Websocket连接，回复503并通过Retry-After建议客户端稍后重试
+func (g *WebsocketGateway[T]) reject(w http.ResponseWriter, ip net.IP, reason int) {
+	glog.Warnf("reject websocket connection from %s, reason: %d\n", ip, reason)
+
+	w.Header().Set("Retry-After", strconv.Itoa(gconf.Config.RejectRetryAfter()))
+	http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+}
+
+// checkOrigin 检查升级请求的来源是否在允许的列表中，未携带Origin的请求通常来自非浏览器客户端，不受限制
+func checkOrigin(r *http.Request) bool {
+	origins := gconf.Config.WebsocketOrigins()
+	origin := r.Header.Get("Origin")
+	if len(origins) == 0 || origin == "" {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+	for _, pattern := range origins {
+		ok, err := path.Match(strings.ToLower(pattern), origin)
+		if err == nil && ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Stop 停止监听Websocket连接，重复调用时直接返回
+func (g *WebsocketGateway[T]) Stop() error {
+	var err error
+	g.stopOnce.Do(func() {
+		close(g.done)
+		g.drain()
+
+		if server := g.server.Load(); server != nil {
+			err = server.Close()
+		}
+	})
+
+	return err
+}
+
+// drain 关闭已升级但还未注册的连接，释放占用的连接数
+func (g *WebsocketGateway[T]) drain() {
+	for {
+		select {
+		case wsConn := <-g.connCh:
+			g.limiter.Release(addrIP(wsConn.remoteAddr))
+			wsConn.conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// connState 在HTTP连接建立时设置TCP连接的选项，设置失败时关闭连接
+func connState(conn net.Conn, state http.ConnState) {
+	stream, ok := conn.(*websocketStream)
+	if state != http.StateNew || !ok {
+		return
+	}
+
+	err := applyConnOptions(stream.tcpConn)
+	if err != nil {
+		glog.Error("Failed to set socket options:", err)
+		conn.Close()
+	}
+}
+
+// websocketFD 通过syscall.Conn获取Websocket底层连接的文件描述符，文件描述符由Go运行时设置为非阻塞模式
+func websocketFD(netConn net.Conn) (int, error) {
+	sc, ok := netConn.(syscall.Conn)
+	if !ok {
+		return 0, fmt.Errorf("websocket underlying conn %T does not implement syscall.Conn", netConn)
+	}
+
+	rawConn, err := sc.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+
+	var fd int
+	err = rawConn.Control(func(s uintptr) {
+		fd = int(s)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return fd, nil
 }
@@ -1,6 +1,8 @@
 package gcore
 
 import (
+	"context"
+	"sync/atomic"
 	"time"
 
 	"github.com/zm50/gte/constant"
@@ -10,46 +12,209 @@ import (
 	"github.com/zm50/gte/trait"
 )
 
-// KeepAliveMgr 连接存活管理器
+// KeepAliveMgr 连接存活管理器，基于时间轮为每个连接创建检测定时器，每个刻度只处理到期的连接
 type KeepAliveMgr[T any] struct {
 	connMgr             trait.ConnMgr[T]
+	taskMgr             trait.TaskMgr[T]
+	metrics             *engineMetrics
+	wheel               *core.TimingWheel
 	healthCheckInterval time.Duration
-	connShards          []*core.KVShard[int32, trait.Connection[T]]
+
+	idlePolicy    int
+	idleMaxMissed int
+	// 读空闲、写空闲与读写空闲时间，为0时不检测
+	idleTimeouts [3]time.Duration
 }
 
-// NewKeepAliveMgr 创建连接存活管理器
-func NewKeepAliveMgr[T any](connMgr trait.ConnMgr[T], connShards []*core.KVShard[int32, trait.Connection[T]]) trait.KeepAliveMgr[T] {
+// NewKeepAliveMgr 创建连接存活管理器，探测与关闭连接等可能阻塞的操作在连接的任务队列中执行
+func NewKeepAliveMgr[T any](connMgr trait.ConnMgr[T], taskMgr trait.TaskMgr[T], metrics *engineMetrics) trait.KeepAliveMgr[T] {
 	return &KeepAliveMgr[T]{
 		connMgr:             connMgr,
+		taskMgr:             taskMgr,
+		metrics:             metrics,
+		wheel:               connMgr.TimingWheel(),
 		healthCheckInterval: time.Millisecond * time.Duration(gconf.Config.HealthCheckInterval()),
-		connShards:          connShards,
+		idlePolicy:          gconf.Config.IdlePolicy(),
+		idleMaxMissed:       max(gconf.Config.IdleMaxMissed(), 1),
+		idleTimeouts: [3]time.Duration{
+			constant.ReaderIdle: time.Millisecond * time.Duration(gconf.Config.ReadIdleTimeout()),
+			constant.WriterIdle: time.Millisecond * time.Duration(gconf.Config.WriteIdleTimeout()),
+			constant.AllIdle:    time.Millisecond * time.Duration(gconf.Config.AllIdleTimeout()),
+		},
 	}
 }
 
 // NewKeepAliveMgr 启动连接存活管理器
 func (m *KeepAliveMgr[T]) Start() {
 	glog.Info("keepalive manager start...")
+}
 
-	for _, connShard := range m.connShards {
-		go m.StartWorker(connShard)
-	}
-}
-
-// StartWorker 启动健康检查工作
-func (k *KeepAliveMgr[T]) StartWorker(connShard *core.KVShard[int32, trait.Connection[T]]) {
-	ticker := time.NewTicker(k.healthCheckInterval)
-	for {
-		<-ticker.C
-		connShard.RRange(func(id int32, conn trait.Connection[T]) {
-			state := conn.State()
-			if state == constant.ConnActiveState {
-				// 设置为检查状态
-				conn.SetState(constant.ConnInspectState)
-			} else if state == constant.ConnInspectState {
-				// 设置为非活跃状态
-				conn.SetState(constant.ConnNotActiveState)
-				k.connMgr.PushConnSignal(NewConnSignal(conn, constant.ConnNotActiveSignal))
-			}
+// Watch 为连接创建健康检查与空闲检测的定时器，连接关闭后定时器自动停止
+func (k *KeepAliveMgr[T]) Watch(conn trait.Connection[T]) {
+	// 是否有已提交但还未执行完成的处置，避免重复提交
+	var settling atomic.Bool
+
+	timer := k.wheel.Every(k.healthCheckInterval, func() {
+		if settling.Load() || !k.inspect(conn, time.Now()) {
+			return
+		}
+
+		// 探测与关闭连接可能阻塞，在连接的任务队列中执行，避免阻塞时间轮的协程
+		settling.Store(true)
+		submitFunc(conn, k.taskMgr, k.metrics, func() {
+			defer settling.Store(false)
+			k.settle(conn, time.Now())
 		})
+	})
+
+	context.AfterFunc(conn.Context(), func() {
+		timer.Stop()
+	})
+
+	for idleState, timeout := range k.idleTimeouts {
+		if timeout > 0 {
+			k.watchIdle(conn, idleState, timeout)
+		}
+	}
+}
+
+// inspect 根据连续未收到数据的巡检周期数更新连接状态，在时间轮的协程中执行，返回连接是否需要探测、通知或关闭
+func (k *KeepAliveMgr[T]) inspect(conn trait.Connection[T], now time.Time) bool {
+	missed := k.missed(conn, now)
+	state := conn.State()
+
+	switch {
+	case state == constant.ConnCloseState:
+		return false
+	case missed == 0:
+		return false
+	case missed < k.idleMaxMissed:
+		if state == constant.ConnActiveState {
+			// 设置为检查状态
+			conn.SetState(constant.ConnInspectState)
+		}
+		return false
+	case state != constant.ConnNotActiveState:
+		return true
+	default:
+		// 探测后的下一个巡检周期仍未收到数据
+		return k.idlePolicy == constant.IdleProbe && missed > k.idleMaxMissed
+	}
+}
+
+// settle 探测、通知或关闭不活跃的连接，在连接的任务队列中执行，等待执行期间收到数据的连接不做处理
+func (k *KeepAliveMgr[T]) settle(conn trait.Connection[T], now time.Time) {
+	missed := k.missed(conn, now)
+	state := conn.State()
+
+	switch {
+	case state == constant.ConnCloseState || missed < k.idleMaxMissed:
+		return
+	case state != constant.ConnNotActiveState:
+		if k.idlePolicy == constant.IdleProbe {
+			// 探测消息需要在连接变为不活跃状态前发送
+			err := conn.Probe()
+			if err != nil {
+				glog.Errorf("probe conn %d err: %v\n", conn.ID(), err)
+			}
+		}
+
+		// 设置为非活跃状态
+		conn.SetState(constant.ConnNotActiveState)
+		k.connMgr.PushConnSignal(NewConnSignal(conn, constant.ConnNotActiveSignal))
+
+		if k.idlePolicy == constant.IdleClose {
+			k.evict(conn)
+		}
+	case k.idlePolicy == constant.IdleProbe && missed > k.idleMaxMissed:
+		k.evict(conn)
+	}
+}
+
+// missed 连续未收到数据的巡检周期数
+func (k *KeepAliveMgr[T]) missed(conn trait.Connection[T], now time.Time) int {
+	return int(now.Sub(conn.LastReadTime()) / k.healthCheckInterval)
+}
+
+// watchIdle 在连接最近一次收发数据的时间加上空闲时间时检查连接是否空闲，期间有数据收发时顺延检查时间，每个空闲期间只通知一次
+func (k *KeepAliveMgr[T]) watchIdle(conn trait.Connection[T], idleState int, timeout time.Duration) {
+	signals := [3]uint8{
+		constant.ReaderIdle: constant.ConnReaderIdleSignal,
+		constant.WriterIdle: constant.ConnWriterIdleSignal,
+		constant.AllIdle:    constant.ConnAllIdleSignal,
+	}
+
+	var (
+		current atomic.Pointer[core.Timer]
+		// 已通知空闲的最近一次收发数据的时间，由时间轮的协程串行访问
+		notified time.Time
+		check    func()
+	)
+
+	check = func() {
+		if conn.Context().Err() != nil {
+			return
+		}
+
+		now := time.Now()
+		last := lastActiveTime(conn, idleState)
+		deadline := last.Add(timeout)
+
+		if !deadline.After(now) {
+			if !last.Equal(notified) {
+				notified = last
+				// 信号队列已满时推送会阻塞，在连接的任务队列中推送
+				submitFunc(conn, k.taskMgr, k.metrics, func() {
+					k.connMgr.PushConnSignal(NewConnSignal(conn, signals[idleState]))
+				})
+			}
+
+			// 空闲期间按照空闲时间间隔检查是否有新的数据收发
+			deadline = now.Add(timeout)
+		}
+
+		current.Store(k.wheel.AfterFunc(deadline.Sub(now), check))
+	}
+
+	current.Store(k.wheel.AfterFunc(timeout, check))
+
+	context.AfterFunc(conn.Context(), func() {
+		current.Load().Stop()
+	})
+}
+
+// lastActiveTime 连接最近一次收发数据的时间
+func lastActiveTime[T any](conn trait.Connection[T], idleState int) time.Time {
+	switch idleState {
+	case constant.ReaderIdle:
+		return conn.LastReadTime()
+	case constant.WriterIdle:
+		return conn.LastWriteTime()
+	}
+
+	lastRead, lastWrite := conn.LastReadTime(), conn.LastWriteTime()
+	if lastWrite.After(lastRead) {
+		return lastWrite
+	}
+
+	return lastRead
+}
+
+// evict 关闭不活跃的连接，触发连接断开的钩子回调
+func (k *KeepAliveMgr[T]) evict(conn trait.Connection[T]) {
+	fd := int32(conn.ID())
+
+	// 连接可能已被删除，文件描述符可能已经被新的连接复用
+	if cur, ok := k.connMgr.Get(fd); !ok || cur != conn {
+		return
+	}
+
+	glog.Infof("evict not active conn %d\n", conn.ID())
+
+	k.metrics.keepAliveEvictions.Add(1)
+
+	err := k.connMgr.Close(fd, constant.CloseByIdle)
+	if err != nil {
+		glog.Error("evict conn err:", err)
 	}
 }
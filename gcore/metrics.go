@@ -0,0 +1,171 @@
+package gcore
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/zm50/gte/constant"
+	"github.com/zm50/gte/glog"
+	"github.com/zm50/gte/trait"
+)
+
+// DefaultLatencyBuckets 请求处理耗时直方图默认的桶上界，单位秒
+var DefaultLatencyBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+var (
+	rejectReasonNames = [constant.RejectReasons]string{
+		constant.RejectMaxConns:         "max_conns",
+		constant.RejectDenied:           "denied",
+		constant.RejectIPLimit:          "ip_limit",
+		constant.RejectCIDRLimit:        "cidr_limit",
+		constant.RejectAcceptRate:       "accept_rate",
+		constant.RejectObserver:         "observer",
+		constant.RejectProxyHeaderReads: "proxy_header_reads",
+	}
+
+	closeReasonNames = [constant.CloseReasons]string{
+		constant.CloseByPeer:      "peer",
+		constant.CloseByError:     "error",
+		constant.CloseByIdle:      "idle",
+		constant.CloseByHandshake: "handshake",
+		constant.CloseByServer:    "server",
+		constant.CloseByShutdown:  "shutdown",
+		constant.CloseByKick:      "kick",
+	}
+
+	priorityNames = [constant.TaskPriorityClasses]string{
+		constant.TaskPriorityHigh:   "high",
+		constant.TaskPriorityNormal: "normal",
+		constant.TaskPriorityLow:    "low",
+	}
+
+	slowKindNames = [constant.SlowKinds]string{
+		constant.SlowHandler:   "handler",
+		constant.SlowQueueWait: "queue_wait",
+	}
+
+	observeEventNames = [constant.ObserveEvents]string{
+		constant.ObserveMessageIn:  "message_in",
+		constant.ObserveMessageOut: "message_out",
+		constant.ObserveConnError:  "conn_error",
+	}
+)
+
+// closeReasonName 关闭原因对应的标签值
+func closeReasonName(reason int) string {
+	if reason < 0 || reason >= constant.CloseReasons {
+		return "unknown"
+	}
+
+	return closeReasonNames[reason]
+}
+
+// engineMetrics 引擎各模块的指标
+type engineMetrics struct {
+	registry trait.MetricsRegistry
+
+	connsAccepted trait.Counter
+	connsRejected trait.Counter
+	connsClosed   trait.Counter
+
+	bytesIn   trait.Counter
+	bytesOut  trait.Counter
+	framesIn  trait.Counter
+	framesOut trait.Counter
+
+	requests        trait.Counter
+	requestDuration trait.Histogram
+	slowRequests    trait.Counter
+
+	keepAliveEvictions trait.Counter
+	panics             trait.Counter
+	observerDropped    trait.Counter
+	timerFuncsDropped  trait.Counter
+}
+
+// newEngineMetrics 在指标注册表中注册引擎的指标
+func newEngineMetrics(registry trait.MetricsRegistry) *engineMetrics {
+	return &engineMetrics{
+		registry: registry,
+
+		connsAccepted: registry.Counter("gte_connections_accepted_total", "Total number of accepted connections."),
+		connsRejected: registry.Counter("gte_connections_rejected_total", "Total number of rejected connections by reason.", "reason"),
+		connsClosed:   registry.Counter("gte_connections_closed_total", "Total number of closed connections by reason.", "reason"),
+
+		bytesIn:   registry.Counter("gte_received_bytes_total", "Total number of bytes received from connections."),
+		bytesOut:  registry.Counter("gte_sent_bytes_total", "Total number of bytes sent to connections."),
+		framesIn:  registry.Counter("gte_received_frames_total", "Total number of frames received from connections."),
+		framesOut: registry.Counter("gte_sent_frames_total", "Total number of frames sent to connections."),
+
+		requests:        registry.Counter("gte_requests_total", "Total number of handled requests by message ID.", "msg_id"),
+		requestDuration: registry.Histogram("gte_request_duration_seconds", "Handler latency of requests by message ID.", DefaultLatencyBuckets, "msg_id"),
+		slowRequests:    registry.Counter("gte_slow_requests_total", "Total number of requests exceeding slow thresholds by message ID and kind.", "msg_id", "kind"),
+
+		keepAliveEvictions: registry.Counter("gte_keepalive_evictions_total", "Total number of connections evicted by keepalive."),
+		panics:             registry.Counter("gte_panics_total", "Total number of recovered handler, observer and timer callback panics."),
+		observerDropped:    registry.Counter("gte_observer_dropped_total", "Total number of observer events dropped because the async queue was full.", "event"),
+		timerFuncsDropped:  registry.Counter("gte_timer_funcs_dropped_total", "Total number of connection timer callbacks dropped because too many were pending."),
+	}
+}
+
+// reset 替换指标注册表并重新注册引擎的指标，各模块共享同一份指标，只能在引擎启动前调用
+func (m *engineMetrics) reset(registry trait.MetricsRegistry) {
+	*m = *newEngineMetrics(registry)
+}
+
+// received 记录收到的一帧数据
+func (m *engineMetrics) received(n int) {
+	m.framesIn.Add(1)
+	m.bytesIn.Add(float64(n))
+}
+
+// sent 记录发送的一帧数据
+func (m *engineMetrics) sent(n int) {
+	m.framesOut.Add(1)
+	m.bytesOut.Add(float64(n))
+}
+
+// handled 记录请求的处理耗时
+func (m *engineMetrics) handled(msgID uint32, latency time.Duration) {
+	id := strconv.FormatUint(uint64(msgID), 10)
+
+	m.requests.Add(1, id)
+	m.requestDuration.Observe(latency.Seconds(), id)
+}
+
+// slow 记录超过阈值的慢请求
+func (m *engineMetrics) slow(msgID uint32, kind int) {
+	m.slowRequests.Add(1, strconv.FormatUint(uint64(msgID), 10), slowKindNames[kind])
+}
+
+// registerCollectors 在指标注册表中注册引擎的在线连接数、工作协程数与队列深度
+func (e *Engine[T]) registerCollectors(registry trait.MetricsRegistry) {
+	online := registry.Gauge("gte_connections_online", "Number of online connections.")
+	dispatcherWorkers := registry.Gauge("gte_dispatcher_workers", "Number of dispatcher workers.")
+	taskWorkers := registry.Gauge("gte_task_workers", "Number of task workers.")
+	dispatcherDepth := registry.Gauge("gte_dispatcher_queue_depth", "Number of connections waiting in dispatcher queues.")
+	taskDepth := registry.Gauge("gte_task_queue_depth", "Number of requests waiting in task queues by priority.", "priority")
+	signalDepth := registry.Gauge("gte_conn_signal_queue_depth", "Number of signals waiting in connection signal queues.")
+
+	registry.OnCollect(func() {
+		online.Set(float64(e.connMgr.OnlineConns()))
+		dispatcherWorkers.Set(float64(e.DispatcherWorkers()))
+		taskWorkers.Set(float64(e.TaskWorkers()))
+		dispatcherDepth.Set(float64(e.connMgr.Dispatcher().PendingConns()))
+		for priority, name := range priorityNames {
+			taskDepth.Set(float64(e.PendingTasks(priority)), name)
+		}
+		signalDepth.Set(float64(e.connMgr.PendingConnSignals()))
+	})
+}
+
+// serveMetrics 以Prometheus文本格式输出引擎当前指标注册表中的指标
+func (e *Engine[T]) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	_, err := e.metrics.registry.WriteTo(w)
+	if err != nil {
+		glog.Error("write metrics error:", err)
+	}
+}
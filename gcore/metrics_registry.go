@@ -0,0 +1,283 @@
+package gcore
+
+import (
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/zm50/gte/trait"
+)
+
+const (
+	counterMetric   = "counter"
+	gaugeMetric     = "gauge"
+	histogramMetric = "histogram"
+)
+
+// MetricsRegistry 不依赖第三方库的指标注册表，以Prometheus文本格式输出指标
+type MetricsRegistry struct {
+	families map[string]*metricFamily
+	// 采集指标前执行的回调
+	collectors []func()
+	lock       sync.RWMutex
+}
+
+var _ trait.MetricsRegistry = (*MetricsRegistry)(nil)
+
+// NewMetricsRegistry 创建指标注册表
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{
+		families: make(map[string]*metricFamily),
+	}
+}
+
+// Counter 注册计数器
+func (r *MetricsRegistry) Counter(name string, help string, labelNames ...string) trait.Counter {
+	return r.register(name, help, counterMetric, nil, labelNames)
+}
+
+// Gauge 注册仪表盘
+func (r *MetricsRegistry) Gauge(name string, help string, labelNames ...string) trait.Gauge {
+	return r.register(name, help, gaugeMetric, nil, labelNames)
+}
+
+// Histogram 注册直方图，buckets为递增的桶上界，+Inf桶自动添加
+func (r *MetricsRegistry) Histogram(name string, help string, buckets []float64, labelNames ...string) trait.Histogram {
+	buckets = slices.Clone(buckets)
+	sort.Float64s(buckets)
+
+	return r.register(name, help, histogramMetric, buckets, labelNames)
+}
+
+// OnCollect 注册采集指标前执行的回调
+func (r *MetricsRegistry) OnCollect(fn func()) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.collectors = append(r.collectors, fn)
+}
+
+// register 注册指标，同名指标已存在时返回已注册的指标
+func (r *MetricsRegistry) register(name string, help string, kind string, buckets []float64, labelNames []string) *metricFamily {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if family, ok := r.families[name]; ok {
+		return family
+	}
+
+	family := &metricFamily{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: slices.Clone(labelNames),
+		buckets:    buckets,
+	}
+	r.families[name] = family
+
+	if len(labelNames) == 0 {
+		// 没有标签的指标在注册后即输出零值
+		family.get(nil)
+	}
+
+	return family
+}
+
+// WriteTo 以Prometheus文本格式输出所有指标，指标与序列按照名称排序
+func (r *MetricsRegistry) WriteTo(w io.Writer) (int64, error) {
+	r.lock.RLock()
+	collectors := slices.Clone(r.collectors)
+	families := make([]*metricFamily, 0, len(r.families))
+	for _, family := range r.families {
+		families = append(families, family)
+	}
+	r.lock.RUnlock()
+
+	for _, collect := range collectors {
+		collect()
+	}
+
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].name < families[j].name
+	})
+
+	var b strings.Builder
+	for _, family := range families {
+		family.write(&b)
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// metricFamily 同名的一组指标，按照标签值区分序列
+type metricFamily struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+	buckets    []float64
+
+	// key: 以\xff连接的标签值, value: *metricSeries
+	series sync.Map
+}
+
+// metricSeries 一组标签值对应的序列，浮点数以位模式原子存储
+type metricSeries struct {
+	labelValues []string
+	value       atomic.Uint64
+
+	// 直方图各个桶的观测次数，不包含+Inf桶
+	bucketCounts []atomic.Uint64
+	count        atomic.Uint64
+}
+
+// get 获取标签值对应的序列，不存在时创建，标签值数量与标签名不一致时截断或补空
+func (f *metricFamily) get(labelValues []string) *metricSeries {
+	key := strings.Join(labelValues, "\xff")
+	if s, ok := f.series.Load(key); ok {
+		return s.(*metricSeries)
+	}
+
+	values := make([]string, len(f.labelNames))
+	copy(values, labelValues)
+
+	s := &metricSeries{labelValues: values}
+	if f.kind == histogramMetric {
+		s.bucketCounts = make([]atomic.Uint64, len(f.buckets))
+	}
+
+	actual, _ := f.series.LoadOrStore(key, s)
+	return actual.(*metricSeries)
+}
+
+// Add 计数器或仪表盘增加delta
+func (f *metricFamily) Add(delta float64, labelValues ...string) {
+	addFloat(&f.get(labelValues).value, delta)
+}
+
+// Set 设置仪表盘的值
+func (f *metricFamily) Set(value float64, labelValues ...string) {
+	f.get(labelValues).value.Store(math.Float64bits(value))
+}
+
+// Observe 直方图记录一次观测值，value记录在sum中
+func (f *metricFamily) Observe(value float64, labelValues ...string) {
+	s := f.get(labelValues)
+
+	i := sort.SearchFloat64s(f.buckets, value)
+	if i < len(s.bucketCounts) {
+		s.bucketCounts[i].Add(1)
+	}
+
+	s.count.Add(1)
+	addFloat(&s.value, value)
+}
+
+// write 输出指标的说明、类型与所有序列
+func (f *metricFamily) write(b *strings.Builder) {
+	var all []*metricSeries
+	f.series.Range(func(_, s any) bool {
+		all = append(all, s.(*metricSeries))
+		return true
+	})
+	if len(all) == 0 {
+		return
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return slices.Compare(all[i].labelValues, all[j].labelValues) < 0
+	})
+
+	b.WriteString("# HELP " + f.name + " " + escapeHelp(f.help) + "\n")
+	b.WriteString("# TYPE " + f.name + " " + f.kind + "\n")
+
+	for _, s := range all {
+		value := math.Float64frombits(s.value.Load())
+		if f.kind != histogramMetric {
+			writeSample(b, f.name, f.labelNames, s.labelValues, "", "", value)
+			continue
+		}
+
+		// 桶的观测次数为小于等于上界的累计值
+		var cumulative uint64
+		for i, bound := range f.buckets {
+			cumulative += s.bucketCounts[i].Load()
+			writeSample(b, f.name+"_bucket", f.labelNames, s.labelValues, "le", formatFloat(bound), float64(cumulative))
+		}
+
+		count := s.count.Load()
+		writeSample(b, f.name+"_bucket", f.labelNames, s.labelValues, "le", "+Inf", float64(count))
+		writeSample(b, f.name+"_sum", f.labelNames, s.labelValues, "", "", value)
+		writeSample(b, f.name+"_count", f.labelNames, s.labelValues, "", "", float64(count))
+	}
+}
+
+// writeSample 输出一行样本，extraName不为空时追加一个标签
+func writeSample(b *strings.Builder, name string, labelNames []string, labelValues []string, extraName string, extraValue string, value float64) {
+	b.WriteString(name)
+
+	if len(labelNames) > 0 || extraName != "" {
+		b.WriteByte('{')
+		for i, labelName := range labelNames {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(labelName + `="` + escapeLabel(labelValues[i]) + `"`)
+		}
+
+		if extraName != "" {
+			if len(labelNames) > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(extraName + `="` + extraValue + `"`)
+		}
+		b.WriteByte('}')
+	}
+
+	b.WriteString(" " + formatFloat(value) + "\n")
+}
+
+// addFloat 原子地为位模式存储的浮点数增加delta
+func addFloat(v *atomic.Uint64, delta float64) {
+	for {
+		cur := v.Load()
+		if v.CompareAndSwap(cur, math.Float64bits(math.Float64frombits(cur)+delta)) {
+			return
+		}
+	}
+}
+
+// formatFloat 按照Prometheus文本格式输出浮点数
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// escapeHelp 转义指标说明中的反斜杠与换行
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+// escapeLabel 转义标签值中的反斜杠、换行与双引号
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
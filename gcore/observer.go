@@ -0,0 +1,205 @@
+package gcore
+
+import (
+	"net"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+
+	"github.com/zm50/gte/constant"
+	"github.com/zm50/gte/gconf"
+	"github.com/zm50/gte/glog"
+	"github.com/zm50/gte/trait"
+)
+
+// Observer 观察者，在收发消息、连接出错与接收连接时调用注册的观察函数，用于审计、抓包与统计分析
+// 除接收连接的观察函数外，观察函数按照配置在触发事件的协程中同步执行，或者提交到事件队列中异步执行，
+// 异步执行时停止观察者会等待队列中的事件执行完成，停止后的事件同步执行，不会丢失
+type Observer[T any] struct {
+	messageIn  func(conn trait.Connection[T], msg trait.Message)
+	messageOut func(conn trait.Connection[T], msg trait.Message)
+	connError  func(conn trait.Connection[T], err error)
+	accept     func(conn net.Conn) bool
+
+	// 异步执行的观察事件队列，同步执行时为空
+	events chan func()
+	// 异步执行时各类观察事件因队列已满被丢弃的次数
+	dropped [constant.ObserveEvents]atomic.Uint64
+
+	// 保护stopped，停止时关闭事件队列，避免向已关闭的队列提交事件
+	mu      sync.RWMutex
+	stopped bool
+	// 异步执行观察事件的协程
+	workers sync.WaitGroup
+
+	metrics *engineMetrics
+}
+
+var _ trait.Observer[any] = (*Observer[any])(nil)
+
+// NewObserver 创建观察者，metrics为引擎的指标
+func NewObserver[T any](metrics *engineMetrics) *Observer[T] {
+	o := &Observer[T]{metrics: metrics}
+	if gconf.Config.ObserverAsync() {
+		o.events = make(chan func(), gconf.Config.ObserverQueueLen())
+	}
+
+	return o
+}
+
+// Start 异步执行时启动执行观察事件的协程
+func (o *Observer[T]) Start() {
+	if o.events == nil {
+		return
+	}
+
+	// 与Stop互斥，停止后不再启动协程，保证Stop等待所有已启动的协程
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.stopped {
+		return
+	}
+
+	for i := 0; i < gconf.Config.ObserverWorkers(); i++ {
+		o.workers.Add(1)
+		go func(queueID int) {
+			defer o.workers.Done()
+			labelGoroutine(roleObserver, queueID)
+
+			// 队列关闭后执行完剩余的事件再退出
+			for fn := range o.events {
+				o.call(fn)
+			}
+		}(i)
+	}
+}
+
+// Stop 停止异步执行观察事件，等待队列中已提交的事件执行完成
+func (o *Observer[T]) Stop() {
+	if o.events == nil {
+		return
+	}
+
+	o.mu.Lock()
+	if o.stopped {
+		o.mu.Unlock()
+		return
+	}
+	o.stopped = true
+	close(o.events)
+	o.mu.Unlock()
+
+	o.workers.Wait()
+}
+
+// OnMessageIn 注册收到客户端消息的观察函数
+func (o *Observer[T]) OnMessageIn(fn func(conn trait.Connection[T], msg trait.Message)) {
+	o.messageIn = fn
+}
+
+// OnMessageOut 注册发送消息给客户端的观察函数
+func (o *Observer[T]) OnMessageOut(fn func(conn trait.Connection[T], msg trait.Message)) {
+	o.messageOut = fn
+}
+
+// OnConnError 注册连接读写出错的观察函数
+func (o *Observer[T]) OnConnError(fn func(conn trait.Connection[T], err error)) {
+	o.connError = fn
+}
+
+// OnAccept 注册接收连接的观察函数，返回false时拒绝连接
+func (o *Observer[T]) OnAccept(fn func(conn net.Conn) bool) {
+	o.accept = fn
+}
+
+// MessageIn 通知收到客户端的消息
+func (o *Observer[T]) MessageIn(conn trait.Connection[T], msg trait.Message) {
+	if o.messageIn == nil {
+		return
+	}
+
+	o.notify(constant.ObserveMessageIn, func() {
+		o.messageIn(conn, msg)
+	})
+}
+
+// MessageOut 通知发送消息给客户端
+func (o *Observer[T]) MessageOut(conn trait.Connection[T], msg trait.Message) {
+	if o.messageOut == nil {
+		return
+	}
+
+	o.notify(constant.ObserveMessageOut, func() {
+		o.messageOut(conn, msg)
+	})
+}
+
+// ConnError 通知连接读写出错
+func (o *Observer[T]) ConnError(conn trait.Connection[T], err error) {
+	if o.connError == nil {
+		return
+	}
+
+	o.notify(constant.ObserveConnError, func() {
+		o.connError(conn, err)
+	})
+}
+
+// Accept 询问是否接收连接，需要根据返回值决定是否接收连接，总是同步执行，未注册观察函数时接收所有连接
+func (o *Observer[T]) Accept(conn net.Conn) bool {
+	if o.accept == nil {
+		return true
+	}
+
+	accepted := true
+	o.call(func() {
+		accepted = o.accept(conn)
+	})
+
+	return accepted
+}
+
+// Dropped 异步执行时观察事件因队列已满被丢弃的次数，event取值为constant中定义的观察事件
+func (o *Observer[T]) Dropped(event int) uint64 {
+	if event < 0 || event >= constant.ObserveEvents {
+		return 0
+	}
+
+	return o.dropped[event].Load()
+}
+
+// notify 同步执行观察函数，或者提交到事件队列中，队列已满时丢弃事件，不阻塞收发消息，观察者停止后同步执行
+func (o *Observer[T]) notify(event int, fn func()) {
+	if o.events == nil {
+		o.call(fn)
+		return
+	}
+
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if o.stopped {
+		o.call(fn)
+		return
+	}
+
+	select {
+	case o.events <- fn:
+	default:
+		o.dropped[event].Add(1)
+		o.metrics.observerDropped.Add(1, observeEventNames[event])
+	}
+}
+
+// call 执行观察函数，观察函数panic时恢复，避免影响收发消息
+func (o *Observer[T]) call(fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			o.metrics.panics.Add(1)
+			glog.Errorf("observer panic: %v\n%s\n", r, debug.Stack())
+		}
+	}()
+
+	fn()
+}
@@ -0,0 +1,196 @@
+package gcore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/pprof"
+	"regexp"
+	rpprof "runtime/pprof"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/zm50/gte/glog"
+)
+
+// 协程的角色，作为pprof标签role的值
+const (
+	roleReactor    = "reactor"
+	roleDispatcher = "dispatcher"
+	roleTaskWorker = "task_worker"
+	roleKeepAlive  = "keepalive"
+	roleConnSignal = "conn_signal"
+	roleObserver   = "observer"
+	// 未设置角色标签的协程
+	roleUnlabeled = "unlabeled"
+)
+
+// roleLabel 匹配协程转储中的角色标签
+var roleLabel = regexp.MustCompile(`"role":"([^"]*)"`)
+
+// workerSeq 工作协程的序号，用于区分同一角色的不同协程
+var workerSeq atomic.Int64
+
+// labelGoroutine 为当前协程设置角色标签，queueID小于0时不设置队列标签，返回带有标签的上下文
+func labelGoroutine(role string, queueID int) context.Context {
+	labels := []string{"role", role, "worker", strconv.FormatInt(workerSeq.Add(1), 10)}
+	if queueID >= 0 {
+		labels = append(labels, "queue", strconv.Itoa(queueID))
+	}
+
+	ctx := rpprof.WithLabels(context.Background(), rpprof.Labels(labels...))
+	rpprof.SetGoroutineLabels(ctx)
+
+	return ctx
+}
+
+// goLabeled 在设置角色标签期间执行fn，fn中创建的协程继承角色标签，用于为其他模块内部创建的协程设置标签
+func goLabeled(role string, fn func()) {
+	rpprof.Do(context.Background(), rpprof.Labels("role", role), func(context.Context) {
+		fn()
+	})
+}
+
+// withMsgIDLabel 执行fn期间为当前协程追加消息ID标签，使CPU分析可以按照路由归类，fn返回后恢复原有标签
+func withMsgIDLabel(ctx context.Context, msgID uint32, fn func()) {
+	rpprof.Do(ctx, rpprof.Labels("msg_id", strconv.FormatUint(uint64(msgID), 10)), func(context.Context) {
+		fn()
+	})
+}
+
+// registerPprof 在管理服务中注册net/http/pprof接口与按角色归类的协程转储接口
+func registerPprof(admin *AdminServer) {
+	admin.HandleFunc("/debug/pprof/", pprof.Index)
+	admin.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	admin.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	admin.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	admin.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	admin.HandleFunc("GET /goroutines", goroutines)
+}
+
+// goroutines 未指定role参数时按照角色统计协程数量，指定role参数时输出该角色所有协程的调用栈
+func goroutines(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	err := rpprof.Lookup("goroutine").WriteTo(&buf, 1)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	role := r.URL.Query().Get("role")
+	counts := make(map[string]int)
+
+	var dump strings.Builder
+	for _, record := range goroutineRecords(buf.Bytes()) {
+		recordRole := roleUnlabeled
+		if match := roleLabel.FindStringSubmatch(record.labels); match != nil {
+			recordRole = match[1]
+		}
+
+		counts[recordRole] += record.count
+
+		if recordRole == role {
+			dump.WriteString(record.text + "\n")
+		}
+	}
+
+	if role == "" {
+		writeJSON(w, http.StatusOK, counts)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, err = w.Write([]byte(dump.String()))
+	if err != nil {
+		glog.Error("write goroutine dump error:", err)
+	}
+}
+
+// goroutineRecord 协程转储中调用栈相同的一组协程
+type goroutineRecord struct {
+	count  int
+	labels string
+	text   string
+}
+
+// goroutineRecords 解析debug=1格式的协程转储，每组协程以"数量 @ 地址"开头，以空行结束
+func goroutineRecords(profile []byte) []goroutineRecord {
+	var (
+		records []goroutineRecord
+		current *goroutineRecord
+		text    strings.Builder
+	)
+
+	flush := func() {
+		if current != nil {
+			current.text = text.String()
+			records = append(records, *current)
+			current = nil
+		}
+		text.Reset()
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(profile))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if line == "" {
+			flush()
+			continue
+		}
+
+		if count, _, ok := strings.Cut(line, " @ "); ok && current == nil {
+			n, err := strconv.Atoi(count)
+			if err == nil {
+				current = &goroutineRecord{count: n}
+			}
+		}
+
+		if current == nil {
+			continue
+		}
+
+		if labels, ok := strings.CutPrefix(line, "# labels: "); ok {
+			current.labels = labels
+		}
+
+		text.WriteString(line + "\n")
+	}
+	flush()
+
+	return records
+}
+
+// sampleStacks 输出带有所有指定标签的协程的调用栈，labels为键值交替的标签列表
+func sampleStacks(labels ...string) (string, error) {
+	var buf bytes.Buffer
+	err := rpprof.Lookup("goroutine").WriteTo(&buf, 1)
+	if err != nil {
+		return "", err
+	}
+
+	matchers := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		matchers = append(matchers, strconv.Quote(labels[i])+":"+strconv.Quote(labels[i+1]))
+	}
+
+	var dump strings.Builder
+	for _, record := range goroutineRecords(buf.Bytes()) {
+		matched := true
+		for _, matcher := range matchers {
+			if !strings.Contains(record.labels, matcher) {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			dump.WriteString(record.text + "\n")
+		}
+	}
+
+	return dump.String(), nil
+}
@@ -0,0 +1,135 @@
+package gcore
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/zm50/gte/core"
+	"github.com/zm50/gte/gconf"
+)
+
+// RealAddrResolver 解析经过代理转发的客户端真实地址，只采信来自可信代理的PROXY协议头部与转发头部
+type RealAddrResolver struct {
+	proxyProtocol      bool
+	proxyHeaderTimeout time.Duration
+	trustedProxies     []*net.IPNet
+	realIPHeaders      []string
+}
+
+// NewRealAddrResolver 基于配置创建客户端真实地址解析器
+func NewRealAddrResolver() (*RealAddrResolver, error) {
+	trustedProxies, err := parseIPNets(gconf.Config.TrustedProxies())
+	if err != nil {
+		return nil, errors.WithMessage(err, "parse trusted proxies failed")
+	}
+
+	realIPHeaders := make([]string, 0, len(gconf.Config.RealIPHeaders()))
+	for _, header := range gconf.Config.RealIPHeaders() {
+		realIPHeaders = append(realIPHeaders, http.CanonicalHeaderKey(header))
+	}
+
+	return &RealAddrResolver{
+		proxyProtocol:      gconf.Config.ProxyProtocol(),
+		proxyHeaderTimeout: time.Duration(gconf.Config.ProxyHeaderTimeout()) * time.Millisecond,
+		trustedProxies:     trustedProxies,
+		realIPHeaders:      realIPHeaders,
+	}, nil
+}
+
+// Trusted 判断地址是否为可信代理
+func (r *RealAddrResolver) Trusted(ip net.IP) bool {
+	return containsIP(r.trustedProxies, ip)
+}
+
+// ConnAddr 获取TCP连接的客户端真实地址，开启PROXY协议且对端为可信代理时读取连接开头的PROXY协议头部
+func (r *RealAddrResolver) ConnAddr(conn net.Conn) (net.Addr, error) {
+	peer := conn.RemoteAddr()
+	if !r.proxyProtocol || !r.Trusted(addrIP(peer)) {
+		return peer, nil
+	}
+
+	err := conn.SetReadDeadline(time.Now().Add(r.proxyHeaderTimeout))
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := core.ReadProxyHeader(conn)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "read proxy header from %s failed", peer)
+	}
+
+	err = conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	if !header.Proxied {
+		return peer, nil
+	}
+
+	return header.Source, nil
+}
+
+// RequestAddr 获取Websocket升级请求的客户端真实地址，对端为可信代理时按顺序查找配置的转发头部
+func (r *RealAddrResolver) RequestAddr(req *http.Request) net.Addr {
+	peer := hostIP(req.RemoteAddr)
+	if len(r.realIPHeaders) == 0 || !r.Trusted(peer) {
+		return remoteAddr(req.RemoteAddr)
+	}
+
+	for _, header := range r.realIPHeaders {
+		values := req.Header.Values(header)
+		if len(values) == 0 {
+			continue
+		}
+
+		var ip net.IP
+		if header == "X-Forwarded-For" {
+			ip = r.forwardedIP(values)
+		} else {
+			ip = net.ParseIP(strings.TrimSpace(values[len(values)-1]))
+		}
+
+		if ip != nil {
+			return &net.TCPAddr{IP: ip}
+		}
+	}
+
+	return remoteAddr(req.RemoteAddr)
+}
+
+// forwardedIP 从右向左查找X-Forwarded-For中第一个不是可信代理的地址，右侧的地址由更靠近服务端的代理追加，左侧的地址可能被客户端伪造
+func (r *RealAddrResolver) forwardedIP(values []string) net.IP {
+	var ips []string
+	for _, value := range values {
+		ips = append(ips, strings.Split(value, ",")...)
+	}
+
+	var ip net.IP
+	for i := len(ips) - 1; i >= 0; i-- {
+		cur := net.ParseIP(strings.TrimSpace(ips[i]))
+		if cur == nil {
+			break
+		}
+
+		ip = cur
+		if !r.Trusted(ip) {
+			break
+		}
+	}
+
+	return ip
+}
+
+// remoteAddr 将host:port格式的地址转换为TCP地址
+func remoteAddr(address string) net.Addr {
+	addr, err := net.ResolveTCPAddr("tcp", address)
+	if err != nil {
+		return &net.TCPAddr{IP: hostIP(address)}
+	}
+
+	return addr
+}
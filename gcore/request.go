@@ -1,20 +1,38 @@
 package gcore
 
-import "github.com/zm50/gte/trait"
+import (
+	"time"
+
+	"github.com/zm50/gte/gpack"
+	"github.com/zm50/gte/gtrace"
+	"github.com/zm50/gte/trait"
+)
 
 // Request 请求对象
 type Request[T any] struct {
 	trait.Connection[T]
 	trait.Message
+
+	// 请求的提交时间，用于统计请求在队列中的等待时间
+	commitTime time.Time
+
+	// 请求的根跨度，未开启链路追踪或未被采样时为空
+	span *gtrace.Span
 }
 
 var _ trait.Request[any] = (*Request[any])(nil)
 
 // NewRequest 创建请求对象
 func NewRequest[T any](conn trait.Connection[T], msg trait.Message) trait.Request[T] {
+	return newRequest(conn, msg)
+}
+
+// newRequest 创建请求对象
+func newRequest[T any](conn trait.Connection[T], msg trait.Message) *Request[T] {
 	return &Request[T]{
 		Connection: conn,
 		Message:    msg,
+		commitTime: time.Now(),
 	}
 }
 
@@ -27,3 +45,41 @@ func (r *Request[T]) Conn() trait.Connection[T] {
 func (r *Request[T]) ID() uint32 {
 	return r.Message.ID()
 }
+
+// CommitTime 请求的提交时间
+func (r *Request[T]) CommitTime() time.Time {
+	return r.commitTime
+}
+
+// Span 请求的根跨度，未开启链路追踪或未被采样时为空
+func (r *Request[T]) Span() *gtrace.Span {
+	return r.span
+}
+
+// FuncRequest 函数请求，在连接的任务队列中执行函数，用于连接的定时回调，不会进入任务处理流
+type FuncRequest[T any] struct {
+	Request[T]
+
+	fn func()
+}
+
+// NewFuncRequest 创建函数请求
+func NewFuncRequest[T any](conn trait.Connection[T], fn func()) *FuncRequest[T] {
+	return &FuncRequest[T]{
+		Request: Request[T]{
+			Connection: conn,
+			Message:    gpack.NewMessage(0, nil),
+			commitTime: time.Now(),
+		},
+		fn: fn,
+	}
+}
+
+// Run 执行函数，连接已关闭时不执行
+func (r *FuncRequest[T]) Run() {
+	if r.Conn().Context().Err() != nil {
+		return
+	}
+
+	r.fn()
+}
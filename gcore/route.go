@@ -0,0 +1,198 @@
+package gcore
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"time"
+
+	"github.com/zm50/gte/constant"
+	"github.com/zm50/gte/trait"
+)
+
+// Route 路由信息，记录消息ID对应的任务执行流与路由配置
+// 路由信息是路由表中的只读快照，修改路由配置时会生成新的快照并替换路由表中的旧快照
+type Route[T any] struct {
+	id      uint32
+	flow    trait.TaskFlow[T]
+	timeout time.Duration
+	// 路由的优先级，决定请求进入的任务队列
+	priority int
+	// 路由是否启用，未启用的路由丢弃对应的请求
+	enabled bool
+	// 路由的名称与描述，便于阅读路由表
+	name string
+	desc string
+	// 注册路由的路由组路径
+	group string
+	// 慢请求的处理与排队阈值，为0时使用全局配置，小于0时不检测
+	slowHandler time.Duration
+	slowQueue   time.Duration
+
+	// 路由所属的路由器
+	router *Router[T]
+}
+
+var _ trait.Route[any] = (*Route[any])(nil)
+
+// NewRoute 创建路由信息
+func NewRoute[T any](id uint32, flow trait.TaskFlow[T]) *Route[T] {
+	return &Route[T]{
+		id:       id,
+		flow:     flow,
+		priority: constant.TaskPriorityNormal,
+		enabled:  true,
+		group:    "/",
+	}
+}
+
+// clone 复制路由信息
+func (r *Route[T]) clone() *Route[T] {
+	route := *r
+	return &route
+}
+
+// update 基于当前路由信息生成修改后的快照，路由属于路由器时同时更新路由表
+func (r *Route[T]) update(fn func(route *Route[T])) *Route[T] {
+	if r.router != nil {
+		if route, ok := r.router.update(r.id, fn); ok {
+			return route
+		}
+	}
+
+	// 路由已注销或不属于任何路由器，仅修改快照
+	route := r.clone()
+	fn(route)
+
+	return route
+}
+
+// ID 路由对应的消息ID
+func (r *Route[T]) ID() uint32 {
+	return r.id
+}
+
+// Flow 路由对应的任务执行流
+func (r *Route[T]) Flow() trait.TaskFlow[T] {
+	return r.flow
+}
+
+// Timeout 路由的处理超时时间，为0时不设置超时
+func (r *Route[T]) Timeout() time.Duration {
+	return r.timeout
+}
+
+// Priority 路由的优先级
+func (r *Route[T]) Priority() int {
+	return r.priority
+}
+
+// Enabled 路由是否启用
+func (r *Route[T]) Enabled() bool {
+	return r.enabled
+}
+
+// Name 路由的名称
+func (r *Route[T]) Name() string {
+	return r.name
+}
+
+// Desc 路由的描述
+func (r *Route[T]) Desc() string {
+	return r.desc
+}
+
+// Group 注册路由的路由组路径
+func (r *Route[T]) Group() string {
+	return r.group
+}
+
+// Handlers 路由任务执行流中各任务处理函数的名称
+func (r *Route[T]) Handlers() []string {
+	funcs := r.flow.Funcs()
+	names := make([]string, len(funcs))
+	for i, fn := range funcs {
+		names[i] = TaskFuncName(fn)
+	}
+
+	return names
+}
+
+// WithTimeout 设置路由的处理超时时间
+func (r *Route[T]) WithTimeout(timeout time.Duration) trait.Route[T] {
+	return r.update(func(route *Route[T]) {
+		route.timeout = timeout
+	})
+}
+
+// SlowHandlerThreshold 路由处理函数执行的慢请求阈值，为0时使用全局配置，小于0时不检测
+func (r *Route[T]) SlowHandlerThreshold() time.Duration {
+	return r.slowHandler
+}
+
+// SlowQueueThreshold 路由的请求在任务队列中等待的慢请求阈值，为0时使用全局配置，小于0时不检测
+func (r *Route[T]) SlowQueueThreshold() time.Duration {
+	return r.slowQueue
+}
+
+// WithSlowHandlerThreshold 设置路由处理函数执行的慢请求阈值
+func (r *Route[T]) WithSlowHandlerThreshold(threshold time.Duration) trait.Route[T] {
+	return r.update(func(route *Route[T]) {
+		route.slowHandler = threshold
+	})
+}
+
+// WithSlowQueueThreshold 设置路由的请求在任务队列中等待的慢请求阈值
+func (r *Route[T]) WithSlowQueueThreshold(threshold time.Duration) trait.Route[T] {
+	return r.update(func(route *Route[T]) {
+		route.slowQueue = threshold
+	})
+}
+
+// WithPriority 设置路由的优先级，取值为constant中定义的任务优先级
+func (r *Route[T]) WithPriority(priority int) trait.Route[T] {
+	if priority < 0 || priority >= constant.TaskPriorityClasses {
+		priority = constant.TaskPriorityNormal
+	}
+
+	return r.update(func(route *Route[T]) {
+		route.priority = priority
+	})
+}
+
+// WithName 设置路由的名称
+func (r *Route[T]) WithName(name string) trait.Route[T] {
+	return r.update(func(route *Route[T]) {
+		route.name = name
+	})
+}
+
+// WithDesc 设置路由的描述
+func (r *Route[T]) WithDesc(desc string) trait.Route[T] {
+	return r.update(func(route *Route[T]) {
+		route.desc = desc
+	})
+}
+
+// WithGroup 设置注册路由的路由组路径
+func (r *Route[T]) WithGroup(group string) trait.Route[T] {
+	return r.update(func(route *Route[T]) {
+		route.group = group
+	})
+}
+
+// TaskFuncName 获取任务处理函数的名称，函数类型返回函数的完整名称，实现了Name方法的类型返回Name方法的结果，其他类型返回类型名称
+func TaskFuncName[T any](fn trait.TaskFunc[T]) string {
+	if named, ok := fn.(interface{ Name() string }); ok {
+		return named.Name()
+	}
+
+	value := reflect.ValueOf(fn)
+	if value.Kind() == reflect.Func {
+		if f := runtime.FuncForPC(value.Pointer()); f != nil {
+			return f.Name()
+		}
+	}
+
+	return fmt.Sprintf("%T", fn)
+}
@@ -1,36 +1,161 @@
 package gcore
 
-import "github.com/zm50/gte/trait"
+import (
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
 
-// Router 任务执行流路由器
+	"github.com/zm50/gte/trait"
+)
+
+// Router 任务执行流路由器，路由表采用写时复制，运行期间可以并发地注册、注销与替换路由
 type Router[T any] struct {
-	apis map[uint32]trait.TaskFlow[T]
+	// 路由表快照，读取时无需加锁
+	apis atomic.Pointer[map[uint32]*Route[T]]
+	// 串行化路由表的修改
+	lock sync.Mutex
 }
 
 var _ trait.Router[any] = (*Router[any])(nil)
 
 // NewRouter 创建一个新的任务流路由器
 func NewRouter[T any]() trait.Router[T] {
-	return &Router[T]{
-		apis: make(map[uint32]trait.TaskFlow[T]),
-	}
+	r := &Router[T]{}
+
+	apis := make(map[uint32]*Route[T])
+	r.apis.Store(&apis)
+
+	return r
+}
+
+// modify 复制路由表并修改，修改完成后替换路由表快照
+func (r *Router[T]) modify(fn func(apis map[uint32]*Route[T])) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	apis := maps.Clone(*r.apis.Load())
+	fn(apis)
+	r.apis.Store(&apis)
+}
+
+// update 修改已注册的路由，路由不存在时返回false
+func (r *Router[T]) update(id uint32, fn func(route *Route[T])) (*Route[T], bool) {
+	var route *Route[T]
+	r.modify(func(apis map[uint32]*Route[T]) {
+		old, ok := apis[id]
+		if !ok {
+			return
+		}
+
+		route = old.clone()
+		fn(route)
+		apis[id] = route
+	})
+
+	return route, route != nil
+}
+
+// Regist 注册任务执行逻辑，消息ID已注册时追加到原有的任务执行流之后
+func (r *Router[T]) Regist(id uint32, flow ...trait.TaskFunc[T]) trait.Route[T] {
+	var route *Route[T]
+	r.modify(func(apis map[uint32]*Route[T]) {
+		if old, ok := apis[id]; ok {
+			route = old.clone()
+			route.flow = old.flow.Append(flow...)
+		} else {
+			route = NewRoute(id, NewTaskFlow(flow...))
+			route.router = r
+		}
+
+		apis[id] = route
+	})
+
+	return route
+}
+
+// RegistFlow 注册一个任务执行执行流，消息ID已注册时替换原有的任务执行流并保留路由配置
+func (r *Router[T]) RegistFlow(id uint32, flow trait.TaskFlow[T]) trait.Route[T] {
+	var route *Route[T]
+	r.modify(func(apis map[uint32]*Route[T]) {
+		if old, ok := apis[id]; ok {
+			route = old.clone()
+			route.flow = flow
+		} else {
+			route = NewRoute(id, flow)
+			route.router = r
+		}
+
+		apis[id] = route
+	})
+
+	return route
+}
+
+// Replace 原子地替换消息ID对应的任务执行逻辑，正在处理的请求继续使用旧的任务执行流
+func (r *Router[T]) Replace(id uint32, flow ...trait.TaskFunc[T]) trait.Route[T] {
+	return r.RegistFlow(id, NewTaskFlow(flow...))
+}
+
+// Unregist 注销消息ID对应的路由，路由不存在时返回false
+func (r *Router[T]) Unregist(id uint32) bool {
+	ok := false
+	r.modify(func(apis map[uint32]*Route[T]) {
+		if _, ok = apis[id]; ok {
+			delete(apis, id)
+		}
+	})
+
+	return ok
+}
+
+// Enable 启用消息ID对应的路由，路由不存在时返回false
+func (r *Router[T]) Enable(id uint32) bool {
+	_, ok := r.update(id, func(route *Route[T]) {
+		route.enabled = true
+	})
+
+	return ok
 }
 
-// Regist 注册任务执行逻辑
-func (r *Router[T]) Regist(id uint32, flow ...trait.TaskFunc[T]) {
-	if _, ok := r.apis[id]; ok {
-		r.apis[id] = r.apis[id].Append(flow...)
-	} else {
-		r.apis[id] = NewTaskFlow(flow...)
+// Disable 停用消息ID对应的路由，停用期间丢弃对应的请求，路由不存在时返回false
+func (r *Router[T]) Disable(id uint32) bool {
+	_, ok := r.update(id, func(route *Route[T]) {
+		route.enabled = false
+	})
+
+	return ok
+}
+
+// Route 根据消息ID获取路由信息
+func (r *Router[T]) Route(id uint32) (trait.Route[T], bool) {
+	route, ok := (*r.apis.Load())[id]
+	if !ok {
+		return nil, false
 	}
+
+	return route, true
 }
 
-// RegistFlow 注册一个任务执行执行流
-func (r *Router[T]) RegistFlow(id uint32, flow trait.TaskFlow[T]) {
-	r.apis[id] = flow
+// Routes 获取所有已注册的路由信息，按照消息ID升序排列
+func (r *Router[T]) Routes() []trait.Route[T] {
+	apis := *r.apis.Load()
+
+	ids := slices.Sorted(maps.Keys(apis))
+	routes := make([]trait.Route[T], len(ids))
+	for i, id := range ids {
+		routes[i] = apis[id]
+	}
+
+	return routes
 }
 
 // TaskFlow 根据消息ID获取任务执行流
 func (r *Router[T]) TaskFlow(id uint32) trait.TaskFlow[T] {
-	return r.apis[id].Fork()
+	route, ok := (*r.apis.Load())[id]
+	if !ok {
+		return nil
+	}
+
+	return route.flow.Fork()
 }
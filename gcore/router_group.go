@@ -1,6 +1,8 @@
 package gcore
 
 import (
+	"path"
+
 	"github.com/zm50/gte/trait"
 )
 
@@ -9,6 +11,9 @@ type RouterGroup[T any] struct {
 	trait.Router[T]
 
 	baseTaskFlow trait.TaskFlow[T]
+
+	// 路由组的路径，用于标识路由的来源
+	path string
 }
 
 var _ trait.RouterGroup[any] = (*RouterGroup[any])(nil)
@@ -18,30 +23,53 @@ func NewRouterGroup[T any](rootRouter trait.Router[T]) trait.RouterGroup[T] {
 	return &RouterGroup[T]{
 		Router:       rootRouter,
 		baseTaskFlow: NewTaskFlow[T](),
+		path:         "/",
 	}
 }
 
-// Group 子路由组
+// Group 子路由组，子路由组与当前路由组的路径相同
 func (g *RouterGroup[T]) Group(flow ...trait.TaskFunc[T]) trait.RouterGroup[T] {
 	group := &RouterGroup[T]{
 		Router:       g.Router,
 		baseTaskFlow: g.baseTaskFlow.Append(flow...),
+		path:         g.path,
+	}
+
+	return group
+}
+
+// NamedGroup 具名子路由组，子路由组的路径为当前路由组的路径拼接上名称
+func (g *RouterGroup[T]) NamedGroup(name string, flow ...trait.TaskFunc[T]) trait.RouterGroup[T] {
+	group := &RouterGroup[T]{
+		Router:       g.Router,
+		baseTaskFlow: g.baseTaskFlow.Append(flow...),
+		path:         path.Join(g.path, name),
 	}
 
 	return group
 }
 
+// Path 路由组的路径
+func (g *RouterGroup[T]) Path() string {
+	return g.path
+}
+
 // Use 注册插件
 func (g *RouterGroup[T]) Use(flow ...trait.TaskFunc[T]) {
 	g.baseTaskFlow = g.baseTaskFlow.Append(flow...)
 }
 
 // Regist 注册任务执行逻辑
-func (g *RouterGroup[T]) Regist(id uint32, flow ...trait.TaskFunc[T]) {
-	g.Router.Regist(id, g.baseTaskFlow.Append(flow...).Funcs()...)
+func (g *RouterGroup[T]) Regist(id uint32, flow ...trait.TaskFunc[T]) trait.Route[T] {
+	return g.Router.Regist(id, g.baseTaskFlow.Append(flow...).Funcs()...).WithGroup(g.path)
+}
+
+// Replace 原子地替换任务执行逻辑，新的任务执行流同样包含路由组的插件
+func (g *RouterGroup[T]) Replace(id uint32, flow ...trait.TaskFunc[T]) trait.Route[T] {
+	return g.Router.Replace(id, g.baseTaskFlow.Append(flow...).Funcs()...).WithGroup(g.path)
 }
 
 // RegistFlow 注册任务执行流
-func (g *RouterGroup[T]) RegistFlow(id uint32, flow trait.TaskFlow[T]) {
-	g.Router.RegistFlow(id, flow)
+func (g *RouterGroup[T]) RegistFlow(id uint32, flow trait.TaskFlow[T]) trait.Route[T] {
+	return g.Router.RegistFlow(id, flow).WithGroup(g.path)
 }
@@ -0,0 +1,130 @@
+package gcore
+
+import (
+	"context"
+	rpprof "runtime/pprof"
+	"strconv"
+	"time"
+
+	"github.com/zm50/gte/constant"
+	"github.com/zm50/gte/gconf"
+	"github.com/zm50/gte/glog"
+	"github.com/zm50/gte/trait"
+)
+
+// slowStackInterval 采集慢请求调用栈的最小间隔，采集协程转储会短暂暂停所有协程，队列积压时避免频繁采集
+const slowStackInterval = time.Second
+
+// SlowRequest 慢请求事件，记录超过阈值的请求、路由、耗时与工作协程的调用栈
+type SlowRequest[T any] struct {
+	trait.Request[T]
+
+	kind      int
+	route     trait.Route[T]
+	elapsed   time.Duration
+	threshold time.Duration
+	stack     string
+}
+
+var _ trait.SlowRequest[any] = (*SlowRequest[any])(nil)
+
+// Kind 慢请求的类型，取值为constant中定义的慢请求类型
+func (r *SlowRequest[T]) Kind() int {
+	return r.kind
+}
+
+// Route 请求对应的路由
+func (r *SlowRequest[T]) Route() trait.Route[T] {
+	return r.route
+}
+
+// Elapsed 检测到慢请求时已经执行或等待的时间
+func (r *SlowRequest[T]) Elapsed() time.Duration {
+	return r.elapsed
+}
+
+// Threshold 触发慢请求的阈值
+func (r *SlowRequest[T]) Threshold() time.Duration {
+	return r.threshold
+}
+
+// Stack 检测到慢请求时相关工作协程的调用栈，距离上一次采集不足采集间隔时为空
+func (r *SlowRequest[T]) Stack() string {
+	return r.stack
+}
+
+// slowThreshold 路由的慢请求阈值，路由未设置时使用以毫秒为单位的全局配置，返回值小于等于0时不检测
+func slowThreshold(routeThreshold time.Duration, configThreshold int) time.Duration {
+	if routeThreshold != 0 {
+		return routeThreshold
+	}
+
+	return time.Duration(configThreshold) * time.Millisecond
+}
+
+// checkQueueWait 请求在任务队列中的等待时间超过阈值时上报慢请求，采集同一队列所有工作协程的调用栈
+func (m *TaskMgr[T]) checkQueueWait(labels context.Context, request trait.Request[T], route trait.Route[T]) {
+	threshold := slowThreshold(route.SlowQueueThreshold(), gconf.Config.SlowQueueThreshold())
+	if threshold <= 0 {
+		return
+	}
+
+	wait := time.Since(request.CommitTime())
+	if wait <= threshold {
+		return
+	}
+
+	queue, _ := rpprof.Label(labels, "queue")
+	go m.reportSlow(&SlowRequest[T]{
+		Request:   request,
+		kind:      constant.SlowQueueWait,
+		route:     route,
+		elapsed:   wait,
+		threshold: threshold,
+	}, "role", roleTaskWorker, "queue", queue)
+}
+
+// watchHandler 处理函数的执行时间超过阈值时上报慢请求，在执行期间采集工作协程的调用栈，
+// 返回的定时器需要在处理结束后停止，未开启检测时返回nil
+func (m *TaskMgr[T]) watchHandler(labels context.Context, request trait.Request[T], route trait.Route[T]) *time.Timer {
+	threshold := slowThreshold(route.SlowHandlerThreshold(), gconf.Config.SlowHandlerThreshold())
+	if threshold <= 0 {
+		return nil
+	}
+
+	start := time.Now()
+	worker, _ := rpprof.Label(labels, "worker")
+
+	return time.AfterFunc(threshold, func() {
+		m.reportSlow(&SlowRequest[T]{
+			Request:   request,
+			kind:      constant.SlowHandler,
+			route:     route,
+			elapsed:   time.Since(start),
+			threshold: threshold,
+		}, "worker", worker)
+	})
+}
+
+// reportSlow 记录慢请求指标，输出结构化的告警日志并调用慢请求钩子，labels为需要采集调用栈的协程标签
+func (m *TaskMgr[T]) reportSlow(event *SlowRequest[T], labels ...string) {
+	m.metrics.slow(event.ID(), event.kind)
+
+	now := time.Now().UnixNano()
+	last := m.lastStackSample.Load()
+	if now-last >= int64(slowStackInterval) && m.lastStackSample.CompareAndSwap(last, now) {
+		stack, err := sampleStacks(labels...)
+		if err != nil {
+			glog.Error("sample slow request stack error:", err)
+		}
+		event.stack = stack
+	}
+
+	glog.Warnf("slow request kind=%s msg_id=%d route=%s conn_id=%d elapsed=%s threshold=%s\n%s",
+		slowKindNames[event.kind], event.ID(), strconv.Quote(event.route.Name()), event.Conn().ID(),
+		event.elapsed, event.threshold, event.stack)
+
+	if m.slowRequestHook != nil {
+		m.slowRequestHook(event)
+	}
+}
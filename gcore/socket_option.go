@@ -0,0 +1,123 @@
+package gcore
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/zm50/gte/gconf"
+)
+
+// syscall包中缺少的Linux TCP选项
+const (
+	tcpUserTimeout = 0x12
+	tcpFastOpen    = 0x17
+)
+
+// listen 基于配置创建TCP监听套接字，在绑定地址后、开始监听前设置TCP Fast Open与延迟接收选项
+func listen(network string, address string) (*net.TCPListener, error) {
+	lc := net.ListenConfig{
+		Control: listenControl,
+		// 由applyConnOptions统一设置接收的连接的保活选项
+		KeepAlive: -1,
+	}
+
+	ln, err := lc.Listen(context.Background(), network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	return ln.(*net.TCPListener), nil
+}
+
+// listenControl 设置监听套接字的选项
+func listenControl(network string, address string, rawConn syscall.RawConn) error {
+	var sockErr error
+	err := rawConn.Control(func(fd uintptr) {
+		if n := gconf.Config.TCPFastOpen(); n > 0 {
+			sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, tcpFastOpen, n)
+			if sockErr != nil {
+				sockErr = errors.WithMessage(sockErr, "set TCP_FASTOPEN failed")
+				return
+			}
+		}
+
+		if secs := gconf.Config.TCPDeferAccept(); secs > 0 {
+			sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_DEFER_ACCEPT, secs)
+			if sockErr != nil {
+				sockErr = errors.WithMessage(sockErr, "set TCP_DEFER_ACCEPT failed")
+				return
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	return sockErr
+}
+
+// applyConnOptions 基于配置设置接收的TCP连接的选项，需要在获取文件描述符之前调用
+func applyConnOptions(conn *net.TCPConn) error {
+	err := conn.SetNoDelay(gconf.Config.TCPNoDelay())
+	if err != nil {
+		return errors.WithMessage(err, "set TCP_NODELAY failed")
+	}
+
+	if gconf.Config.TCPKeepAlive() {
+		err = conn.SetKeepAliveConfig(net.KeepAliveConfig{
+			Enable:   true,
+			Idle:     time.Duration(gconf.Config.TCPKeepAliveIdle()) * time.Second,
+			Interval: time.Duration(gconf.Config.TCPKeepAliveInterval()) * time.Second,
+			Count:    gconf.Config.TCPKeepAliveCount(),
+		})
+	} else {
+		err = conn.SetKeepAlive(false)
+	}
+	if err != nil {
+		return errors.WithMessage(err, "set SO_KEEPALIVE failed")
+	}
+
+	if size := gconf.Config.TCPRecvBuffer(); size > 0 {
+		err = conn.SetReadBuffer(size)
+		if err != nil {
+			return errors.WithMessage(err, "set SO_RCVBUF failed")
+		}
+	}
+
+	if size := gconf.Config.TCPSendBuffer(); size > 0 {
+		err = conn.SetWriteBuffer(size)
+		if err != nil {
+			return errors.WithMessage(err, "set SO_SNDBUF failed")
+		}
+	}
+
+	if secs := gconf.Config.TCPLinger(); secs >= 0 {
+		err = conn.SetLinger(secs)
+		if err != nil {
+			return errors.WithMessage(err, "set SO_LINGER failed")
+		}
+	}
+
+	if ms := gconf.Config.TCPUserTimeout(); ms > 0 {
+		rawConn, err := conn.SyscallConn()
+		if err != nil {
+			return err
+		}
+
+		var sockErr error
+		err = rawConn.Control(func(fd uintptr) {
+			sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, tcpUserTimeout, ms)
+		})
+		if err != nil {
+			return err
+		}
+		if sockErr != nil {
+			return errors.WithMessage(sockErr, "set TCP_USER_TIMEOUT failed")
+		}
+	}
+
+	return nil
+}
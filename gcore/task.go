@@ -1,6 +1,15 @@
 package gcore
 
-import "github.com/zm50/gte/trait"
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+
+	"github.com/zm50/gte/constant"
+	"github.com/zm50/gte/trait"
+)
 
 // TaskFunc 任务处理函数
 type TaskFunc[T any] func(trait.Context[T])
@@ -57,89 +66,177 @@ func (h *TaskFlow[T]) Funcs() []trait.TaskFunc[T] {
 	return *h
 }
 
-// StatefulFunc 有状态函数
-type StatefulFunc[T any] struct {
-	Data T
-	Func func(trait.Context[T], T)
+// StatefulFunc 有状态函数，执行时从所属的有状态函数流中获取状态
+type StatefulFunc[T any, S any] struct {
+	flow *StatefulFuncFlow[T, S]
+	Func func(trait.Context[T], S)
 }
 
 // NewStatefulFunc 创建有状态函数
-func NewStatefulFunc[T any](fn func(trait.Context[T], T)) *StatefulFunc[T] {
-	return &StatefulFunc[T]{
+func NewStatefulFunc[T any, S any](flow *StatefulFuncFlow[T, S], fn func(trait.Context[T], S)) *StatefulFunc[T, S] {
+	return &StatefulFunc[T, S]{
+		flow: flow,
 		Func: fn,
 	}
 }
 
 // Execute 执行任务
-func (f *StatefulFunc[T]) Execute(ctx trait.Context[T]) {
-	f.Func(ctx, f.Data)
+func (f *StatefulFunc[T, S]) Execute(ctx trait.Context[T]) {
+	f.Func(ctx, f.flow.State(ctx))
 }
 
-// StatefulFuncFlow 有状态函数流
-type StatefulFuncFlow[T any] struct {
-	dataProvide func() T
-	Data        T
-	FuncFlow    []*StatefulFunc[T]
+// Name 有状态函数的名称
+func (f *StatefulFunc[T, S]) Name() string {
+	return runtime.FuncForPC(reflect.ValueOf(f.Func).Pointer()).Name()
 }
 
-// NewStatefulFuncFlow 创建有状态函数流
-func NewStatefulFuncFlow[T any](dataProvide func() T) *StatefulFuncFlow[T] {
-	return &StatefulFuncFlow[T]{}
+// StatefulFuncFlow 有状态函数流，流中的函数共享同一份状态，状态的作用域可以是连接、路由或请求
+// 状态由dataProvide在首次使用时创建，同一作用域内的并发请求共享状态，需要由使用者保证状态的并发安全
+type StatefulFuncFlow[T any, S any] struct {
+	scope       int
+	dataProvide func() S
+	release     func(S)
+	FuncFlow    []*StatefulFunc[T, S]
+
+	// 路由级别的状态
+	routeState     S
+	routeStateOnce sync.Once
+
+	// 连接级别的状态，key: 连接
+	connStates     map[trait.Connection[T]]S
+	connStatesLock sync.RWMutex
+
+	// 请求级别的状态在请求上下文中的键
+	requestStateKey string
 }
 
-// Regist 注册有状态函数
-func (f *StatefulFuncFlow[T]) Regist(fns ...func(trait.Context[T], T)) {
-	for i := 0; i < len(fns); i++ {
-		statefulFunc := NewStatefulFunc(fns[i])
-		f.FuncFlow = append(f.FuncFlow, statefulFunc)
+// NewStatefulFuncFlow 创建有状态函数流，状态默认为连接级别
+func NewStatefulFuncFlow[T any, S any](dataProvide func() S) *StatefulFuncFlow[T, S] {
+	f := &StatefulFuncFlow[T, S]{
+		scope:       constant.StateScopeConn,
+		dataProvide: dataProvide,
+		connStates:  make(map[trait.Connection[T]]S),
 	}
+
+	f.requestStateKey = fmt.Sprintf("gte.stateful.%p", f)
+
+	return f
 }
 
-// Append 添加函数到流中
-func (f *StatefulFuncFlow[T]) Append(fs ...trait.TaskFunc[T]) trait.TaskFlow[T] {
-	flow := make([]trait.TaskFunc[T], f.Len()+len(fs))
+// WithScope 设置状态的作用域，取值为constant中定义的状态作用域，需要在注册路由前设置
+func (f *StatefulFuncFlow[T, S]) WithScope(scope int) *StatefulFuncFlow[T, S] {
+	f.scope = scope
+	return f
+}
 
-	taskFuncs := make([]trait.TaskFunc[T], len(f.FuncFlow))
+// WithRelease 设置状态的释放函数，连接级别的状态在连接关闭时释放，请求级别的状态在请求处理结束时释放
+func (f *StatefulFuncFlow[T, S]) WithRelease(release func(S)) *StatefulFuncFlow[T, S] {
+	f.release = release
+	return f
+}
 
-	for i, fn := range f.FuncFlow {
-		taskFuncs[i] = fn
+// State 获取请求所在作用域的状态，状态不存在时创建
+func (f *StatefulFuncFlow[T, S]) State(ctx trait.Context[T]) S {
+	switch f.scope {
+	case constant.StateScopeRoute:
+		f.routeStateOnce.Do(func() {
+			f.routeState = f.dataProvide()
+		})
+
+		return f.routeState
+	case constant.StateScopeRequest:
+		if state, ok := ctx.Get(f.requestStateKey); ok {
+			return state.(S)
+		}
+
+		state := f.dataProvide()
+		ctx.Set(f.requestStateKey, state)
+		f.releaseAfter(ctx.Context(), state)
+
+		return state
+	default:
+		return f.connState(ctx.Conn())
+	}
+}
+
+// connState 获取连接级别的状态，状态不存在时创建，并在连接关闭时释放
+func (f *StatefulFuncFlow[T, S]) connState(conn trait.Connection[T]) S {
+	f.connStatesLock.RLock()
+	state, ok := f.connStates[conn]
+	f.connStatesLock.RUnlock()
+	if ok {
+		return state
 	}
 
-	copy(flow[:f.Len()], taskFuncs)
-	copy(flow[f.Len():], fs)
+	f.connStatesLock.Lock()
+	defer f.connStatesLock.Unlock()
 
-	return f
+	if state, ok := f.connStates[conn]; ok {
+		return state
+	}
+
+	state = f.dataProvide()
+	f.connStates[conn] = state
+
+	context.AfterFunc(conn.Context(), func() {
+		f.connStatesLock.Lock()
+		delete(f.connStates, conn)
+		f.connStatesLock.Unlock()
+
+		if f.release != nil {
+			f.release(state)
+		}
+	})
+
+	return state
 }
 
-// Fork 复制一个新的有状态函数流
-func (f *StatefulFuncFlow[T]) Fork() trait.TaskFlow[T] {
-	flow := &StatefulFuncFlow[T]{
-		Data: f.dataProvide(),
+// releaseAfter 上下文结束后释放状态
+func (f *StatefulFuncFlow[T, S]) releaseAfter(ctx context.Context, state S) {
+	if f.release == nil {
+		return
 	}
 
-	statefulFuncs := make([]*StatefulFunc[T], len(f.FuncFlow))
+	context.AfterFunc(ctx, func() {
+		f.release(state)
+	})
+}
 
-	for i, fn := range f.FuncFlow {
-		statefulFuncs[i] = NewStatefulFunc(fn.Func)
+// Regist 注册有状态函数，需要在注册路由前调用
+func (f *StatefulFuncFlow[T, S]) Regist(fns ...func(trait.Context[T], S)) *StatefulFuncFlow[T, S] {
+	for i := 0; i < len(fns); i++ {
+		f.FuncFlow = append(f.FuncFlow, NewStatefulFunc(f, fns[i]))
 	}
 
-	return flow
+	return f
+}
+
+// Append 添加函数到流中，返回包含有状态函数与追加函数的新任务执行流，有状态函数仍然使用当前流的状态
+func (f *StatefulFuncFlow[T, S]) Append(fs ...trait.TaskFunc[T]) trait.TaskFlow[T] {
+	flow := make([]trait.TaskFunc[T], 0, f.Len()+len(fs))
+	flow = append(flow, f.Funcs()...)
+	flow = append(flow, fs...)
+
+	return NewTaskFlow(flow...)
+}
+
+// Fork 复制有状态函数流，状态按照作用域保存，复制的流与原有的流共享状态
+func (f *StatefulFuncFlow[T, S]) Fork() trait.TaskFlow[T] {
+	return f
 }
 
 // Execute 执行任务
-func (f *StatefulFuncFlow[T]) Execute(idx int, ctx trait.Context[T]) {
-	statefulFunc := f.FuncFlow[idx]
-	statefulFunc.Data = f.Data
-	statefulFunc.Execute(ctx)
+func (f *StatefulFuncFlow[T, S]) Execute(idx int, ctx trait.Context[T]) {
+	f.FuncFlow[idx].Execute(ctx)
 }
 
 // Len 获取函数流长度
-func (f *StatefulFuncFlow[T]) Len() int {
+func (f *StatefulFuncFlow[T, S]) Len() int {
 	return len(f.FuncFlow)
 }
 
 // Funcs 获取函数清单
-func (f *StatefulFuncFlow[T]) Funcs() []trait.TaskFunc[T] {
+func (f *StatefulFuncFlow[T, S]) Funcs() []trait.TaskFunc[T] {
 	taskFuncs := make([]trait.TaskFunc[T], len(f.FuncFlow))
 	for i, fn := range f.FuncFlow {
 		taskFuncs[i] = fn
@@ -1,8 +1,16 @@
 package gcore
 
 import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"github.com/zm50/gte/constant"
 	"github.com/zm50/gte/gconf"
 	"github.com/zm50/gte/glog"
+	"github.com/zm50/gte/gtrace"
 	"github.com/zm50/gte/trait"
 )
 
@@ -10,57 +18,222 @@ import (
 type TaskMgr[T any] struct {
 	trait.RouterGroup[T]
 
-	taskQueues []chan trait.Request[T]
+	taskQueues []*TaskQueue[T]
+	scalers    []*WorkerScaler
+
+	// 握手阶段允许访问的消息ID，为空时不开启握手阶段
+	handshakeIDs map[uint32]struct{}
+
+	// 慢请求的回调函数
+	slowRequestHook func(event trait.SlowRequest[T])
+	// 上一次采集慢请求调用栈的时间，单位纳秒
+	lastStackSample atomic.Int64
+
+	metrics *engineMetrics
+
+	// 链路追踪器，为空时不追踪请求
+	tracer atomic.Pointer[gtrace.Tracer]
 }
 
 var _ trait.TaskMgr[any] = (*TaskMgr[any])(nil)
 
-// NewTaskMgr 创建任务管理器
-func NewTaskMgr[T any]() trait.TaskMgr[T] {
-	taskQueues := make([]chan trait.Request[T], gconf.Config.TaskQueues())
+// NewTaskMgr 创建任务管理器，metrics为引擎的指标
+func NewTaskMgr[T any](metrics *engineMetrics) trait.TaskMgr[T] {
+	taskQueues := make([]*TaskQueue[T], gconf.Config.TaskQueues())
 	for i := 0; i < len(taskQueues); i++ {
-		taskQueues[i] = make(chan trait.Request[T], gconf.Config.TaskQueueLen())
+		taskQueues[i] = NewTaskQueue[T](gconf.Config.TaskQueueLen(), gconf.Config.TaskSchedulePolicy(), gconf.Config.TaskPriorityWeights())
 	}
 
 	// 新建任务处理路由器与分组路由
 	rootRouter := NewRouter[T]()
 	routerGroup := NewRouterGroup(rootRouter)
 
-	return &TaskMgr[T]{
-		RouterGroup: routerGroup,
-		taskQueues:  taskQueues,
+	handshakeIDs := make(map[uint32]struct{})
+	for _, id := range gconf.Config.HandshakeMsgIDs() {
+		handshakeIDs[id] = struct{}{}
+	}
+
+	m := &TaskMgr[T]{
+		RouterGroup:  routerGroup,
+		taskQueues:   taskQueues,
+		scalers:      make([]*WorkerScaler, len(taskQueues)),
+		handshakeIDs: handshakeIDs,
+		metrics:      metrics,
 	}
+
+	for i := 0; i < len(taskQueues); i++ {
+		queueID := i
+		m.scalers[i] = NewWorkerScaler(gconf.Config.MinWorkersPerTaskQueue(), gconf.Config.MaxWorkersPerTaskQueue(),
+			taskQueues[i].Depth, func() { m.StartWorker(queueID) })
+	}
+
+	return m
 }
 
 // Start 启动任务管理器
-func (m *TaskMgr[T]) Start() {
+func (m *TaskMgr[T]) Start(ctx context.Context) {
 	glog.Info("task manager start...")
 
-	for i := 0; i < len(m.taskQueues); i++ {
-		for j := 0; j < gconf.Config.WorkersPerTaskQueue(); j++ {
-			go m.StartWorker(m.taskQueues[i])
+	for _, scaler := range m.scalers {
+		scaler.Start(ctx, gconf.Config.WorkersPerTaskQueue())
+	}
+}
+
+// StartWorker 启动任务消费者，按照调度策略消费任务队列中各优先级的请求，收到伸缩器的退出通知时返回
+func (m *TaskMgr[T]) StartWorker(queueID int) {
+	taskQueue := m.taskQueues[queueID]
+	scaler := m.scalers[queueID]
+	credits := taskQueue.Credits()
+	labels := labelGoroutine(roleTaskWorker, queueID)
+
+	for {
+		request, ok := taskQueue.Pop(credits, scaler.Quit())
+		if !ok {
+			return
 		}
+
+		scaler.Begin(time.Since(request.CommitTime()))
+		m.handle(labels, request)
+		scaler.Done()
+	}
+}
+
+// handle 执行请求对应的任务执行流，labels为工作协程的pprof标签
+func (m *TaskMgr[T]) handle(labels context.Context, request trait.Request[T]) {
+	span := request.Span()
+
+	// 连接的定时回调同样需要恢复panic，避免工作协程退出，任务流执行结束后结束请求的根跨度
+	defer func() {
+		if r := recover(); r != nil {
+			m.metrics.panics.Add(1)
+			span.SetAttribute("panic", fmt.Sprint(r))
+			glog.Errorf("handle request panic, msg id: %d conn id: %d err: %v\n%s\n", request.ID(), request.Conn().ID(), r, debug.Stack())
+		}
+
+		span.End()
+	}()
+
+	if funcRequest, ok := request.(*FuncRequest[T]); ok {
+		// 连接的定时回调
+		funcRequest.Run()
+		return
+	}
+
+	// 记录请求在任务队列中的等待
+	span.StartChildAt(spanQueue, request.CommitTime()).End()
+
+	if !m.handshakeAllowed(request) {
+		glog.Warnf("connection not authenticated, drop msg id: %d conn id: %d\n", request.ID(), request.Conn().ID())
+		span.SetAttribute("dropped", "unauthenticated")
+		return
+	}
+	route, ok := m.Route(request.ID())
+	if !ok {
+		glog.Warnf("route not found, msg id: %d\n", request.ID())
+		span.SetAttribute("dropped", "route_not_found")
+		return
+	}
+
+	if !route.Enabled() {
+		// 路由已停用，丢弃请求
+		span.SetAttribute("dropped", "route_disabled")
+		return
+	}
+
+	m.checkQueueWait(labels, request, route)
+
+	start := time.Now()
+	if watch := m.watchHandler(labels, request, route); watch != nil {
+		defer watch.Stop()
+	}
+
+	ctx := NewContext(request, route.Flow().Fork(), route.Timeout())
+	// 处理函数panic时同样释放上下文，避免子上下文在连接的上下文中堆积
+	defer ctx.Release()
+
+	if gconf.Config.AdminPprof() {
+		withMsgIDLabel(labels, request.ID(), ctx.Run)
+	} else {
+		ctx.Run()
 	}
+
+	m.metrics.handled(request.ID(), time.Since(start))
+}
+
+// OnSlowRequest 注册慢请求的回调函数，在独立的协程中调用
+func (m *TaskMgr[T]) OnSlowRequest(fn func(event trait.SlowRequest[T])) {
+	m.slowRequestHook = fn
 }
 
-// StartWorker 启动任务消费者
-func (m *TaskMgr[T]) StartWorker(taskQueue <-chan trait.Request[T]) {
-	for request := range taskQueue {
-		flow := m.TaskFlow(request.ID())
-		ctx := NewContext(request, flow)
-		ctx.Next()
+// SetTracer 设置链路追踪器，为nil时关闭链路追踪
+func (m *TaskMgr[T]) SetTracer(tracer *gtrace.Tracer) {
+	m.tracer.Store(tracer)
+}
+
+// Tracer 获取链路追踪器，未开启链路追踪时返回nil
+func (m *TaskMgr[T]) Tracer() *gtrace.Tracer {
+	return m.tracer.Load()
+}
+
+// handshakeAllowed 连接通过握手鉴权前，只允许访问握手阶段的消息ID
+func (m *TaskMgr[T]) handshakeAllowed(request trait.Request[T]) bool {
+	if len(m.handshakeIDs) == 0 || request.Conn().IsAuthenticated() {
+		return true
 	}
+
+	_, ok := m.handshakeIDs[request.ID()]
+	return ok
 }
 
 // ChooseQueue 选择处理连接的队列
-func (m *TaskMgr[T]) ChooseQueue(connID uint64) chan<- trait.Request[T] {
+func (m *TaskMgr[T]) ChooseQueue(connID uint64, priority int) chan<- trait.Request[T] {
 	// 负载均衡，选择队列
-	return m.taskQueues[connID%uint64(len(m.taskQueues))]
+	return m.taskQueues[connID%uint64(len(m.taskQueues))].Queue(priority)
 }
 
-// Submit 提交任务
+// Submit 提交任务，按照消息ID对应路由的优先级选择队列，队列已满时阻塞等待
 func (m *TaskMgr[T]) Submit(request trait.Request[T]) {
-	m.ChooseQueue(request.Conn().ID()) <- request
+	m.chooseRequestQueue(request) <- request
+}
+
+// TrySubmit 尝试提交任务，队列已满时不阻塞并返回false
+func (m *TaskMgr[T]) TrySubmit(request trait.Request[T]) bool {
+	select {
+	case m.chooseRequestQueue(request) <- request:
+		return true
+	default:
+		return false
+	}
+}
+
+// chooseRequestQueue 按照消息ID对应路由的优先级选择请求的队列
+func (m *TaskMgr[T]) chooseRequestQueue(request trait.Request[T]) chan<- trait.Request[T] {
+	priority := constant.TaskPriorityNormal
+	if route, ok := m.Route(request.ID()); ok {
+		priority = route.Priority()
+	}
+
+	return m.ChooseQueue(request.Conn().ID(), priority)
+}
+
+// PendingTasks 获取指定优先级的所有队列中待处理的请求数量
+func (m *TaskMgr[T]) PendingTasks(priority int) int {
+	pending := 0
+	for _, taskQueue := range m.taskQueues {
+		pending += taskQueue.Len(priority)
+	}
+
+	return pending
+}
+
+// Workers 获取所有队列的任务消费者数量
+func (m *TaskMgr[T]) Workers() int {
+	workers := 0
+	for _, scaler := range m.scalers {
+		workers += scaler.Workers()
+	}
+
+	return workers
 }
 
 // Use 注册插件
@@ -69,11 +242,16 @@ func (m *TaskMgr[T]) Use(flow ...trait.TaskFunc[T]) {
 }
 
 // Regist 注册任务流
-func (m *TaskMgr[T]) Regist(id uint32, flow ...trait.TaskFunc[T]) {
-	m.RouterGroup.Regist(id, flow...)
+func (m *TaskMgr[T]) Regist(id uint32, flow ...trait.TaskFunc[T]) trait.Route[T] {
+	return m.RouterGroup.Regist(id, flow...)
+}
+
+// Replace 替换任务流
+func (m *TaskMgr[T]) Replace(id uint32, flow ...trait.TaskFunc[T]) trait.Route[T] {
+	return m.RouterGroup.Replace(id, flow...)
 }
 
 // Regist 注册任务流
-func (m *TaskMgr[T]) RegistFlow(id uint32, flow trait.TaskFlow[T]) {
-	m.RouterGroup.RegistFlow(id, flow)
+func (m *TaskMgr[T]) RegistFlow(id uint32, flow trait.TaskFlow[T]) trait.Route[T] {
+	return m.RouterGroup.RegistFlow(id, flow)
 }
@@ -0,0 +1,128 @@
+package gcore
+
+import (
+	"github.com/zm50/gte/constant"
+	"github.com/zm50/gte/trait"
+)
+
+// TaskQueue 任务队列，按照优先级划分为多个子队列，不同优先级的请求互不阻塞
+type TaskQueue[T any] struct {
+	queues  [constant.TaskPriorityClasses]chan trait.Request[T]
+	policy  int
+	weights [constant.TaskPriorityClasses]int
+}
+
+// NewTaskQueue 创建任务队列
+func NewTaskQueue[T any](queueLen int, policy int, weights []int) *TaskQueue[T] {
+	q := &TaskQueue[T]{
+		policy: policy,
+	}
+
+	for i := 0; i < constant.TaskPriorityClasses; i++ {
+		q.queues[i] = make(chan trait.Request[T], queueLen)
+
+		// 未配置或配置非法的权重按1处理
+		q.weights[i] = 1
+		if i < len(weights) && weights[i] > 0 {
+			q.weights[i] = weights[i]
+		}
+	}
+
+	return q
+}
+
+// Queue 获取指定优先级的子队列
+func (q *TaskQueue[T]) Queue(priority int) chan<- trait.Request[T] {
+	return q.queues[priority]
+}
+
+// Len 获取指定优先级的子队列中待处理的请求数量
+func (q *TaskQueue[T]) Len(priority int) int {
+	return len(q.queues[priority])
+}
+
+// Depth 获取所有子队列中待处理的请求数量
+func (q *TaskQueue[T]) Depth() int {
+	depth := 0
+	for i := 0; i < constant.TaskPriorityClasses; i++ {
+		depth += len(q.queues[i])
+	}
+
+	return depth
+}
+
+// Credits 创建调度额度，每个消费者持有一份，用于加权调度
+func (q *TaskQueue[T]) Credits() []int {
+	credits := make([]int, constant.TaskPriorityClasses)
+	copy(credits, q.weights[:])
+	return credits
+}
+
+// Pop 按照调度策略取出一个请求，队列为空时阻塞等待，收到退出通知时返回false
+func (q *TaskQueue[T]) Pop(credits []int, quit <-chan struct{}) (trait.Request[T], bool) {
+	if q.policy == constant.StrictPrioritySchedule {
+		return q.popStrict(quit)
+	}
+
+	return q.popWeighted(credits, quit)
+}
+
+// popStrict 严格优先级调度，总是优先消费高优先级的子队列
+func (q *TaskQueue[T]) popStrict(quit <-chan struct{}) (trait.Request[T], bool) {
+	for i := 0; i < constant.TaskPriorityClasses; i++ {
+		select {
+		case request, ok := <-q.queues[i]:
+			return request, ok
+		default:
+		}
+	}
+
+	return q.popWait(nil, quit)
+}
+
+// popWeighted 加权调度，每一轮中各优先级最多消费权重数量的请求，额度用尽或队列为空时开始下一轮
+func (q *TaskQueue[T]) popWeighted(credits []int, quit <-chan struct{}) (trait.Request[T], bool) {
+	for round := 0; round < 2; round++ {
+		for i := 0; i < constant.TaskPriorityClasses; i++ {
+			if credits[i] <= 0 {
+				continue
+			}
+
+			select {
+			case request, ok := <-q.queues[i]:
+				credits[i]--
+				return request, ok
+			default:
+			}
+		}
+
+		// 有额度的子队列都为空，重置额度
+		copy(credits, q.weights[:])
+	}
+
+	return q.popWait(credits, quit)
+}
+
+// popWait 阻塞等待任一子队列的请求
+func (q *TaskQueue[T]) popWait(credits []int, quit <-chan struct{}) (trait.Request[T], bool) {
+	var request trait.Request[T]
+	var ok bool
+	var priority int
+
+	select {
+	case request, ok = <-q.queues[constant.TaskPriorityHigh]:
+		priority = constant.TaskPriorityHigh
+	case request, ok = <-q.queues[constant.TaskPriorityNormal]:
+		priority = constant.TaskPriorityNormal
+	case request, ok = <-q.queues[constant.TaskPriorityLow]:
+		priority = constant.TaskPriorityLow
+	case <-quit:
+		return nil, false
+	}
+
+	if credits != nil {
+		credits[priority]--
+	}
+
+	return request, ok
+}
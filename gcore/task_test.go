@@ -0,0 +1,168 @@
+package gcore
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zm50/gte/constant"
+	"github.com/zm50/gte/gpack"
+	"github.com/zm50/gte/trait"
+)
+
+// testConn 测试用的连接，只实现任务流使用的方法
+type testConn struct {
+	trait.Connection[int]
+
+	id     uint64
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newTestConn(id uint64) *testConn {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &testConn{id: id, ctx: ctx, cancel: cancel}
+}
+
+func (c *testConn) ID() uint64 {
+	return c.id
+}
+
+func (c *testConn) Context() context.Context {
+	return c.ctx
+}
+
+// testState 测试用的状态，记录被执行的次数
+type testState struct {
+	calls atomic.Int64
+}
+
+// waitFor 等待条件成立，超时后测试失败
+func waitFor(t *testing.T, desc string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", desc)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStatefulFuncFlowConcurrent(t *testing.T) {
+	const (
+		conns           = 16
+		requestsPerConn = 50
+		total           = conns * requestsPerConn
+	)
+
+	tests := []struct {
+		name  string
+		scope int
+		// 期望创建的状态数量
+		states int
+	}{
+		{name: "conn", scope: constant.StateScopeConn, states: conns},
+		{name: "route", scope: constant.StateScopeRoute, states: 1},
+		{name: "request", scope: constant.StateScopeRequest, states: total},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				created  []*testState
+				lock     sync.Mutex
+				released atomic.Int64
+			)
+
+			var flow *StatefulFuncFlow[int, *testState]
+			flow = NewStatefulFuncFlow[int](func() *testState {
+				state := &testState{}
+
+				lock.Lock()
+				created = append(created, state)
+				lock.Unlock()
+
+				return state
+			}).WithScope(tt.scope).WithRelease(func(*testState) {
+				released.Add(1)
+			}).Regist(func(ctx trait.Context[int], state *testState) {
+				state.calls.Add(1)
+			}, func(ctx trait.Context[int], state *testState) {
+				// 同一请求中的函数共享同一份状态
+				if state != flow.State(ctx) {
+					t.Error("functions of one request got different states")
+				}
+			})
+
+			testConns := make([]*testConn, conns)
+			for i := range testConns {
+				testConns[i] = newTestConn(uint64(i))
+			}
+
+			var wg sync.WaitGroup
+			for _, conn := range testConns {
+				for i := 0; i < requestsPerConn; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+
+						ctx := NewContext(NewRequest[int](conn, gpack.NewMessage(1, nil)), flow.Fork(), 0)
+						defer ctx.Release()
+
+						ctx.Run()
+					}()
+				}
+			}
+			wg.Wait()
+
+			lock.Lock()
+			states := created
+			lock.Unlock()
+
+			if len(states) != tt.states {
+				t.Fatalf("created %d states, want %d", len(states), tt.states)
+			}
+
+			var calls int64
+			for _, state := range states {
+				calls += state.calls.Load()
+			}
+			if calls != total {
+				t.Fatalf("states were called %d times, want %d", calls, total)
+			}
+
+			if tt.scope == constant.StateScopeConn {
+				for _, state := range states {
+					if n := state.calls.Load(); n != requestsPerConn {
+						t.Fatalf("conn state was called %d times, want %d", n, requestsPerConn)
+					}
+				}
+
+				if released.Load() != 0 {
+					t.Fatalf("conn states released before connections closed")
+				}
+
+				for _, conn := range testConns {
+					conn.cancel()
+				}
+
+				waitFor(t, "conn states released", func() bool {
+					flow.connStatesLock.RLock()
+					defer flow.connStatesLock.RUnlock()
+
+					return len(flow.connStates) == 0 && released.Load() == conns
+				})
+			}
+
+			if tt.scope == constant.StateScopeRequest {
+				waitFor(t, "request states released", func() bool {
+					return released.Load() == total
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package gcore
+
+import (
+	"time"
+
+	"github.com/zm50/gte/gtrace"
+	"github.com/zm50/gte/trait"
+)
+
+// 请求链路中各阶段跨度的名称
+const (
+	spanRequest = "gte.request"
+	spanRead    = "gte.read"
+	spanQueue   = "gte.queue"
+	spanHandler = "gte.handler"
+	spanSend    = "gte.send"
+)
+
+// trace 开启链路追踪时为请求创建根跨度，以消息携带的链路上下文为父跨度，并记录从start开始读取帧的跨度，tracer为空时不追踪
+func (r *Request[T]) trace(tracer *gtrace.Tracer, start time.Time, size int) {
+	span := tracer.StartAt(spanRequest, r.Message.TraceContext(), start)
+	if span == nil {
+		return
+	}
+
+	span.SetAttribute("msg_id", r.ID())
+	span.SetAttribute("conn_id", r.Connection.ID())
+
+	read := span.StartChildAt(spanRead, start)
+	read.SetAttribute("bytes", size)
+	read.End()
+
+	r.span = span
+}
+
+// endDropped 请求未进入任务队列而被丢弃时结束请求的根跨度
+func endDropped[T any](request trait.Request[T], reason string) {
+	span := request.Span()
+	span.SetAttribute("dropped", reason)
+	span.End()
+}
@@ -0,0 +1,251 @@
+package gcore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"slices"
+	"syscall"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"github.com/zm50/gte/gconf"
+	"github.com/zm50/gte/glog"
+)
+
+const (
+	// 每次从套接字读取数据的最小缓冲区大小
+	websocketReadChunk = 4096
+	// 控制帧负载的最大长度
+	websocketMaxControlPayload = 125
+)
+
+// websocketListener Websocket网关的监听器，接收的连接包装为websocketStream
+type websocketListener struct {
+	*net.TCPListener
+}
+
+// Accept 接收连接，包装失败的连接被关闭，不影响HTTP服务继续接收连接
+func (l *websocketListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.AcceptTCP()
+		if err != nil {
+			return nil, err
+		}
+
+		stream, err := newWebsocketStream(conn)
+		if err != nil {
+			glog.Error("Failed to wrap websocket conn:", err)
+			continue
+		}
+
+		return stream, nil
+	}
+}
+
+// websocketStream Websocket连接的底层字节流
+// 升级前直接读取套接字，升级完成后由连接非阻塞地读取套接字中的数据并解析帧头部，
+// Ping与Pong在解析时处理，只有完整的数据消息与其余控制帧交给websocket库读取，保证读取消息时不会阻塞epoll的协程
+type websocketStream struct {
+	net.Conn
+
+	tcpConn *net.TCPConn
+	rawConn syscall.RawConn
+
+	// 是否已开始解析帧，升级完成后开始
+	scanning bool
+
+	// 从套接字读取但还未解析完整的数据
+	in []byte
+	// 解析完成等待websocket库读取的数据
+	out bytes.Buffer
+	// out中完整消息与控制帧的数量
+	messages int
+
+	// 数据消息负载的最大长度，解析帧头部时拒绝超过最大长度的帧，不缓冲其负载
+	maxMessage uint64
+	// out中未结束的分片消息已缓冲的负载长度
+	fragmented uint64
+
+	// 收到Ping与Pong时回调的函数
+	onPing func(appData string) error
+	onPong func(appData string) error
+}
+
+// newWebsocketStream 包装接收的TCP连接
+func newWebsocketStream(conn *net.TCPConn) (*websocketStream, error) {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &websocketStream{
+		Conn:       conn,
+		tcpConn:    conn,
+		rawConn:    rawConn,
+		maxMessage: uint64(max(gconf.Config.MaxPacketSize(), 0)),
+	}, nil
+}
+
+// SyscallConn 获取底层连接的原始连接，用于获取文件描述符
+func (s *websocketStream) SyscallConn() (syscall.RawConn, error) {
+	return s.rawConn, nil
+}
+
+// scan 开始解析帧，升级完成后调用
+func (s *websocketStream) scan(onPing, onPong func(appData string) error) {
+	s.onPing = onPing
+	s.onPong = onPong
+	s.scanning = true
+}
+
+// Read 升级前读取套接字，开始解析帧后只读取已解析完成的数据
+func (s *websocketStream) Read(b []byte) (int, error) {
+	if !s.scanning {
+		return s.Conn.Read(b)
+	}
+
+	if s.out.Len() == 0 {
+		// 只有完整的消息会交给websocket库读取，不会发生
+		return 0, syscall.EAGAIN
+	}
+
+	return s.out.Read(b)
+}
+
+// pending 是否有完整的消息等待读取
+func (s *websocketStream) pending() bool {
+	return s.messages > 0
+}
+
+// consume 读取一条完整的消息后调用
+func (s *websocketStream) consume() {
+	s.messages--
+}
+
+// fill 非阻塞地读取套接字中的数据并解析，返回读取的字节数，套接字中没有数据时返回0
+// 帧的长度超过限制时返回*websocket.CloseError，调用方需要发送对应关闭码的关闭帧后关闭连接
+func (s *websocketStream) fill() (int, error) {
+	s.in = slices.Grow(s.in, websocketReadChunk)
+
+	var n int
+	var readErr error
+	err := s.rawConn.Read(func(fd uintptr) bool {
+		n, readErr = syscall.Read(int(fd), s.in[len(s.in):cap(s.in)])
+		// 返回true，套接字中没有数据时不等待
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	if readErr == syscall.EAGAIN || readErr == syscall.EINTR {
+		return 0, nil
+	}
+	if readErr != nil {
+		return 0, readErr
+	}
+	if n == 0 {
+		return 0, io.EOF
+	}
+
+	s.in = s.in[:len(s.in)+n]
+
+	return n, s.parse()
+}
+
+// parse 解析读取的完整帧，不完整的帧保留到下次读取
+func (s *websocketStream) parse() error {
+	off := 0
+	defer func() {
+		s.in = s.in[:copy(s.in, s.in[off:])]
+	}()
+
+	for {
+		frame := s.in[off:]
+		if len(frame) < 2 {
+			return nil
+		}
+
+		final := frame[0]&0x80 != 0
+		opcode := int(frame[0] & 0x0f)
+		masked := frame[1]&0x80 != 0
+
+		headerLen := 2
+		length := uint64(frame[1] & 0x7f)
+		switch length {
+		case 126:
+			headerLen += 2
+			if len(frame) < headerLen {
+				return nil
+			}
+			length = uint64(binary.BigEndian.Uint16(frame[2:]))
+		case 127:
+			headerLen += 8
+			if len(frame) < headerLen {
+				return nil
+			}
+			length = binary.BigEndian.Uint64(frame[2:])
+		}
+		if masked {
+			headerLen += 4
+		}
+
+		// 在缓冲负载前检查声明的长度，避免客户端声明超长的帧后缓慢发送数据占用内存
+		if opcode > websocket.BinaryMessage && length > websocketMaxControlPayload {
+			return &websocket.CloseError{Code: websocket.CloseProtocolError, Text: "control frame too big"}
+		}
+
+		// 数据消息已缓冲的负载长度，后续分片累加之前分片的长度
+		fragmented := length
+		if opcode == 0 {
+			fragmented += s.fragmented
+		}
+		if opcode <= websocket.BinaryMessage && s.maxMessage > 0 && (fragmented < length || fragmented > s.maxMessage) {
+			return &websocket.CloseError{Code: websocket.CloseMessageTooBig, Text: "message too big"}
+		}
+
+		if len(frame) < headerLen || uint64(len(frame)-headerLen) < length {
+			return nil
+		}
+		frameLen := headerLen + int(length)
+		off += frameLen
+
+		// 格式正确的Ping与Pong在解析时处理，其余控制帧交给websocket库处理，读取时返回关闭或协议错误
+		if (opcode == websocket.PingMessage || opcode == websocket.PongMessage) && final && masked && length <= websocketMaxControlPayload {
+			payload := make([]byte, length)
+			copy(payload, frame[headerLen:frameLen])
+			key := frame[headerLen-4 : headerLen]
+			for i := range payload {
+				payload[i] ^= key[i&3]
+			}
+
+			var err error
+			if opcode == websocket.PingMessage {
+				err = s.onPing(string(payload))
+			} else {
+				err = s.onPong(string(payload))
+			}
+			if err != nil {
+				return errors.WithMessage(err, "handle websocket control frame err")
+			}
+
+			continue
+		}
+
+		s.out.Write(frame[:frameLen])
+
+		if opcode <= websocket.BinaryMessage {
+			if final {
+				fragmented = 0
+			}
+			s.fragmented = fragmented
+		}
+
+		// 数据消息的最后一帧与其余控制帧都会在读取时返回
+		if final || opcode > websocket.BinaryMessage {
+			s.messages++
+		}
+	}
+}
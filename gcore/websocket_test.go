@@ -0,0 +1,268 @@
+package gcore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/zm50/gte/constant"
+	"github.com/zm50/gte/gconf"
+	"github.com/zm50/gte/gpack"
+	"github.com/zm50/gte/trait"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// echoMsgID 测试用的回显路由
+	echoMsgID = 1
+	// websocketMaxPacketSize 测试引擎的消息最大长度
+	websocketMaxPacketSize = 1 << 17
+)
+
+// websocketEngine 启动Websocket网络模式的引擎并返回连接地址，日志是进程级别的，测试进程中只启动一次
+var websocketEngine = sync.OnceValues(func() (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	logDir, err := os.MkdirTemp("", "gte")
+	if err != nil {
+		return "", err
+	}
+
+	klog.LogToStderr(false)
+	gconf.Config.WithListenIP("127.0.0.1").WithListenPort(port).WithNetworkMode(constant.WebsocketNetworkMode).
+		WithWebsocketPaths([]string{"/ws"}).WithMaxPacketSize(websocketMaxPacketSize).WithLogFilename(filepath.Join(logDir, "gte.log"))
+
+	engine, err := NewEngine[int]()
+	if err != nil {
+		return "", err
+	}
+	engine.Regist(echoMsgID, func(ctx trait.Context[int]) {
+		ctx.SendMsg(echoMsgID, ctx.Data())
+	})
+
+	go engine.Run()
+
+	return fmt.Sprintf("ws://127.0.0.1:%d/ws", port), nil
+})
+
+// startWebsocketEngine 获取Websocket引擎的连接地址，等待网关开始监听
+func startWebsocketEngine(t *testing.T) string {
+	t.Helper()
+
+	url, err := websocketEngine()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "websocket gateway listening", func() bool {
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	})
+
+	return url
+}
+
+// clientFrame 构造客户端发送的带掩码的帧
+func clientFrame(opcode int, final bool, payload []byte) []byte {
+	b0 := byte(opcode)
+	if final {
+		b0 |= 0x80
+	}
+
+	frame := []byte{b0}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, 0x80|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+
+	key := [4]byte{0x12, 0x34, 0x56, 0x78}
+	frame = append(frame, key[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^key[i&3])
+	}
+
+	return frame
+}
+
+// echoPayload 回显路由的消息
+func echoPayload(data string) []byte {
+	return gpack.PackWebsocket(gpack.NewMessage(echoMsgID, []byte(data)))
+}
+
+// readEcho 读取回显的消息
+func readEcho(t *testing.T, conn *websocket.Conn) string {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read echo err: %v", err)
+	}
+
+	msg, err := gpack.UnpackWebsocket(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(msg.Data())
+}
+
+func TestWebsocketConnection(t *testing.T) {
+	url := startWebsocketEngine(t)
+
+	tests := []struct {
+		name string
+		// 分多次写入的原始帧
+		writes [][]byte
+		// 期望回显的消息，多个任务协程并发处理，回显的顺序不固定
+		echoes []string
+		// 期望收到的Pong
+		pongs int
+		// 期望服务端关闭连接的关闭码
+		closeCode int
+	}{
+		{
+			name:   "control frames only",
+			writes: [][]byte{clientFrame(websocket.PingMessage, true, []byte("ping")), clientFrame(websocket.PongMessage, true, nil)},
+			pongs:  1,
+		},
+		{
+			name: "frames in one segment",
+			writes: [][]byte{bytes.Join([][]byte{
+				clientFrame(websocket.BinaryMessage, true, echoPayload("a")),
+				clientFrame(websocket.BinaryMessage, true, echoPayload("b")),
+				clientFrame(websocket.PingMessage, true, nil),
+				clientFrame(websocket.BinaryMessage, true, echoPayload("c")),
+			}, nil)},
+			echoes: []string{"a", "b", "c"},
+			pongs:  1,
+		},
+		{
+			name: "fragmented message split across segments",
+			writes: func() [][]byte {
+				payload := echoPayload("fragmented")
+				frames := append(clientFrame(websocket.BinaryMessage, false, payload[:3]), clientFrame(websocket.PingMessage, true, nil)...)
+				frames = append(frames, clientFrame(0, true, payload[3:])...)
+				return [][]byte{frames[:5], frames[5:11], frames[11:]}
+			}(),
+			echoes: []string{"fragmented"},
+			pongs:  1,
+		},
+		{
+			name:   "large message",
+			writes: [][]byte{clientFrame(websocket.BinaryMessage, true, echoPayload(string(bytes.Repeat([]byte("x"), 70000))))},
+			echoes: []string{string(bytes.Repeat([]byte("x"), 70000))},
+		},
+		{
+			name: "declared frame length over max packet size",
+			// 只发送超长帧的头部，服务端不等待负载
+			writes:    [][]byte{clientFrame(websocket.BinaryMessage, true, make([]byte, websocketMaxPacketSize+1))[:14]},
+			closeCode: websocket.CloseMessageTooBig,
+		},
+		{
+			name: "fragmented message over max packet size",
+			writes: [][]byte{
+				clientFrame(websocket.BinaryMessage, false, make([]byte, websocketMaxPacketSize/2)),
+				clientFrame(0, false, make([]byte, websocketMaxPacketSize/2)),
+				clientFrame(0, true, []byte("x"))[:6],
+			},
+			closeCode: websocket.CloseMessageTooBig,
+		},
+		{
+			name:      "control frame over max control payload",
+			writes:    [][]byte{clientFrame(websocket.PingMessage, true, make([]byte, 126))[:8]},
+			closeCode: websocket.CloseProtocolError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			pongs := make(chan string, 16)
+			conn.SetPongHandler(func(appData string) error {
+				pongs <- appData
+				return nil
+			})
+
+			for _, data := range tt.writes {
+				_, err := conn.NetConn().Write(data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			// 只收到控制帧的连接不能阻塞其他连接的读取
+			other, _, err := websocket.DefaultDialer.Dial(url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer other.Close()
+
+			err = other.WriteMessage(websocket.BinaryMessage, echoPayload("other"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := readEcho(t, other); got != "other" {
+				t.Fatalf("other conn echo %q, want %q", got, "other")
+			}
+
+			echoes := make([]string, 0, len(tt.echoes))
+			for range tt.echoes {
+				echoes = append(echoes, readEcho(t, conn))
+			}
+			slices.Sort(echoes)
+			if !slices.Equal(echoes, tt.echoes) {
+				t.Fatalf("echoes %q, want %q", echoes, tt.echoes)
+			}
+
+			if tt.closeCode != 0 {
+				conn.SetReadDeadline(time.Now().Add(time.Second))
+				_, _, err := conn.ReadMessage()
+				if !websocket.IsCloseError(err, tt.closeCode) {
+					t.Fatalf("read err %v, want close code %d", err, tt.closeCode)
+				}
+			}
+
+			// 读取回显或等待超时时处理服务端回复的Pong
+			if tt.pongs > 0 {
+				go conn.ReadMessage()
+				for i := 0; i < tt.pongs; i++ {
+					select {
+					case <-pongs:
+					case <-time.After(time.Second):
+						t.Fatalf("received %d pongs, want %d", i, tt.pongs)
+					}
+				}
+			}
+		})
+	}
+}
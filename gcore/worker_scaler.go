@@ -0,0 +1,156 @@
+package gcore
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/zm50/gte/gconf"
+)
+
+// WorkerScaler 工作协程伸缩器，根据队列的积压深度与等待延迟，在最小与最大数量之间调整消费同一队列的工作协程数量
+type WorkerScaler struct {
+	minWorkers int32
+	maxWorkers int32
+
+	// 当前的工作协程数量
+	workers atomic.Int32
+	// 正在处理任务的工作协程数量
+	busy atomic.Int32
+	// 一个伸缩周期内观测到的最大等待延迟，单位纳秒
+	maxWait atomic.Int64
+	// 连续空闲的伸缩周期数
+	idleRounds int
+
+	// 通知一个空闲的工作协程退出
+	quit chan struct{}
+
+	// 获取队列的积压深度
+	depth func() int
+	// 工作协程的消费逻辑，收到退出通知时返回
+	work func()
+}
+
+// NewWorkerScaler 创建工作协程伸缩器
+func NewWorkerScaler(minWorkers int, maxWorkers int, depth func() int, work func()) *WorkerScaler {
+	if minWorkers < 1 {
+		minWorkers = 1
+	}
+	if maxWorkers < minWorkers {
+		maxWorkers = minWorkers
+	}
+
+	return &WorkerScaler{
+		minWorkers: int32(minWorkers),
+		maxWorkers: int32(maxWorkers),
+		quit:       make(chan struct{}),
+		depth:      depth,
+		work:       work,
+	}
+}
+
+// Start 启动指定数量的工作协程，开启自动伸缩时按照配置的周期调整工作协程数量，ctx取消后停止伸缩
+func (s *WorkerScaler) Start(ctx context.Context, workers int) {
+	if gconf.Config.WorkerAutoScale() {
+		workers = min(max(workers, int(s.minWorkers)), int(s.maxWorkers))
+	}
+
+	for i := 0; i < workers; i++ {
+		s.spawn()
+	}
+
+	if !gconf.Config.WorkerAutoScale() {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(gconf.Config.WorkerScaleInterval()) * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.Scale()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// spawn 启动一个工作协程
+func (s *WorkerScaler) spawn() {
+	s.workers.Add(1)
+
+	go func() {
+		defer s.workers.Add(-1)
+		s.work()
+	}()
+}
+
+// Scale 执行一次伸缩检查，队列积压或等待延迟超过阈值时扩容，连续多个周期空闲时缩容
+func (s *WorkerScaler) Scale() {
+	depth := s.depth()
+	wait := time.Duration(s.maxWait.Swap(0))
+	workers := s.workers.Load()
+
+	overloaded := depth >= gconf.Config.WorkerScaleUpQueueLen() ||
+		wait >= time.Duration(gconf.Config.WorkerScaleUpWaitTime())*time.Millisecond
+
+	if overloaded {
+		s.idleRounds = 0
+
+		// 扩容时工作协程数量翻倍，尽快消化积压
+		grow := min(workers, s.maxWorkers-workers)
+		for i := int32(0); i < grow; i++ {
+			s.spawn()
+		}
+
+		return
+	}
+
+	if depth > 0 || s.busy.Load() >= workers {
+		s.idleRounds = 0
+		return
+	}
+
+	s.idleRounds++
+	if s.idleRounds < gconf.Config.WorkerScaleDownIdleRounds() || workers <= s.minWorkers {
+		return
+	}
+
+	s.idleRounds = 0
+
+	// 缩容时每次只退出一个空闲的工作协程，没有空闲的工作协程时放弃本次缩容
+	select {
+	case s.quit <- struct{}{}:
+	default:
+	}
+}
+
+// Begin 工作协程开始处理任务，wait为任务在队列中的等待时间
+func (s *WorkerScaler) Begin(wait time.Duration) {
+	s.busy.Add(1)
+
+	for {
+		cur := s.maxWait.Load()
+		if int64(wait) <= cur || s.maxWait.CompareAndSwap(cur, int64(wait)) {
+			break
+		}
+	}
+}
+
+// Done 工作协程处理任务结束
+func (s *WorkerScaler) Done() {
+	s.busy.Add(-1)
+}
+
+// Quit 工作协程的退出通知
+func (s *WorkerScaler) Quit() <-chan struct{} {
+	return s.quit
+}
+
+// Workers 当前的工作协程数量
+func (s *WorkerScaler) Workers() int {
+	return int(s.workers.Load())
+}
@@ -0,0 +1,40 @@
+package gmiddleware
+
+import (
+	"slices"
+
+	"github.com/zm50/gte/gcore"
+	"github.com/zm50/gte/trait"
+)
+
+// AuthConfig 鉴权中间件配置
+type AuthConfig[T any] struct {
+	// 判断连接是否已通过鉴权，为空时使用连接的握手鉴权状态
+	Authenticated func(conn trait.Connection[T]) bool
+	// 无需鉴权即可访问的消息ID，例如登录消息
+	SkipIDs []uint32
+	// 拒绝请求时的回调，为空时直接中止任务流
+	OnReject func(ctx trait.Context[T])
+}
+
+// Auth 鉴权中间件，连接通过鉴权前拒绝访问除SkipIDs之外的路由
+func Auth[T any](config AuthConfig[T]) gcore.TaskFunc[T] {
+	authenticated := config.Authenticated
+	if authenticated == nil {
+		authenticated = func(conn trait.Connection[T]) bool {
+			return conn.IsAuthenticated()
+		}
+	}
+
+	return func(ctx trait.Context[T]) {
+		if slices.Contains(config.SkipIDs, ctx.ID()) {
+			return
+		}
+
+		if authenticated(ctx.Conn()) {
+			return
+		}
+
+		reject(ctx, config.OnReject)
+	}
+}
@@ -0,0 +1,49 @@
+package gmiddleware
+
+import (
+	"testing"
+
+	"github.com/zm50/gte/trait"
+)
+
+func TestAuth(t *testing.T) {
+	tests := []struct {
+		name          string
+		authenticated bool
+		custom        func(conn trait.Connection[int]) bool
+		skipIDs       []uint32
+		msgID         uint32
+		wantHandled   bool
+	}{
+		{name: "authenticated", authenticated: true, msgID: 1, wantHandled: true},
+		{name: "not authenticated", msgID: 1, wantHandled: false},
+		{name: "skip id", skipIDs: []uint32{1, 2}, msgID: 2, wantHandled: true},
+		{name: "not in skip ids", skipIDs: []uint32{1, 2}, msgID: 3, wantHandled: false},
+		{name: "custom allow", custom: func(trait.Connection[int]) bool { return true }, msgID: 1, wantHandled: true},
+		{name: "custom deny", authenticated: true, custom: func(trait.Connection[int]) bool { return false }, msgID: 1, wantHandled: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newTestConn(1)
+			conn.authenticated = tt.authenticated
+
+			rejected := false
+			auth := Auth(AuthConfig[int]{
+				Authenticated: tt.custom,
+				SkipIDs:       tt.skipIDs,
+				OnReject: func(ctx trait.Context[int]) {
+					rejected = true
+				},
+			})
+
+			ctx, handled := run(conn, tt.msgID, auth)
+			if handled != tt.wantHandled {
+				t.Fatalf("handled = %t, want %t", handled, tt.wantHandled)
+			}
+			if rejected == tt.wantHandled || ctx.IsAborted() == tt.wantHandled {
+				t.Fatalf("rejected = %t aborted = %t, want %t", rejected, ctx.IsAborted(), !tt.wantHandled)
+			}
+		})
+	}
+}
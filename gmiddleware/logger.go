@@ -0,0 +1,41 @@
+package gmiddleware
+
+import (
+	"slices"
+	"time"
+
+	"github.com/zm50/gte/gcore"
+	"github.com/zm50/gte/glog"
+	"github.com/zm50/gte/trait"
+)
+
+// AccessLogConfig 访问日志中间件配置
+type AccessLogConfig[T any] struct {
+	// 不记录访问日志的消息ID，例如心跳消息
+	SkipIDs []uint32
+	// 自定义日志内容，为空时使用默认格式
+	Formatter func(ctx trait.Context[T], latency time.Duration) string
+}
+
+// AccessLog 访问日志中间件，任务流执行结束后通过glog记录请求的处理结果与耗时
+func AccessLog[T any](config AccessLogConfig[T]) gcore.TaskFunc[T] {
+	return func(ctx trait.Context[T]) {
+		if slices.Contains(config.SkipIDs, ctx.ID()) {
+			return
+		}
+
+		start := time.Now()
+
+		ctx.Next()
+
+		latency := time.Since(start)
+
+		if config.Formatter != nil {
+			glog.Info(config.Formatter(ctx, latency))
+			return
+		}
+
+		glog.Infof("access msg id: %d conn id: %d remote: %s data len: %d aborted: %t latency: %s\n",
+			ctx.ID(), ctx.Conn().ID(), ctx.Conn().RemoteAddr(), ctx.DataLen(), ctx.IsAborted(), latency)
+	}
+}
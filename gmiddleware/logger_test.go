@@ -0,0 +1,69 @@
+package gmiddleware
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zm50/gte/gcore"
+	"github.com/zm50/gte/trait"
+)
+
+func TestAccessLog(t *testing.T) {
+	slow := gcore.TaskFunc[int](func(ctx trait.Context[int]) {
+		time.Sleep(5 * time.Millisecond)
+	})
+	abort := gcore.TaskFunc[int](func(ctx trait.Context[int]) {
+		ctx.Abort()
+	})
+
+	tests := []struct {
+		name        string
+		msgID       uint32
+		handler     gcore.TaskFunc[int]
+		wantLogged  bool
+		wantAborted bool
+	}{
+		{name: "logged", msgID: 1, handler: slow, wantLogged: true},
+		{name: "aborted", msgID: 1, handler: abort, wantLogged: true, wantAborted: true},
+		{name: "skipped", msgID: 2, handler: slow, wantLogged: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logged := false
+			accessLog := AccessLog(AccessLogConfig[int]{
+				SkipIDs: []uint32{2},
+				Formatter: func(ctx trait.Context[int], latency time.Duration) string {
+					logged = true
+
+					if ctx.IsAborted() != tt.wantAborted {
+						t.Errorf("aborted = %t, want %t", ctx.IsAborted(), tt.wantAborted)
+					}
+					if !tt.wantAborted && latency < 5*time.Millisecond {
+						t.Errorf("latency %s does not cover the handler", latency)
+					}
+
+					return "formatted access log"
+				},
+			})
+
+			_, handled := run(newTestConn(1), tt.msgID, accessLog, tt.handler)
+			if logged != tt.wantLogged {
+				t.Fatalf("logged = %t, want %t", logged, tt.wantLogged)
+			}
+			// 跳过的请求不影响后续任务的执行
+			if handled == tt.wantAborted {
+				t.Fatalf("handled = %t, want %t", handled, !tt.wantAborted)
+			}
+		})
+	}
+}
+
+func TestAccessLogDefaultFormat(t *testing.T) {
+	run(newTestConn(7), 42, AccessLog(AccessLogConfig[int]{}))
+
+	if !strings.Contains(logs.String(), "access msg id: 42 conn id: 7 remote: 127.0.0.1:7 data len: 4 aborted: false") {
+		t.Fatalf("default access log not found in:\n%s", logs)
+	}
+}
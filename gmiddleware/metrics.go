@@ -0,0 +1,126 @@
+package gmiddleware
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zm50/gte/core"
+	"github.com/zm50/gte/gcore"
+	"github.com/zm50/gte/trait"
+)
+
+// RouteStat 路由的请求统计
+type RouteStat struct {
+	// 请求数量
+	Count uint64
+	// 被中止的请求数量
+	Aborted uint64
+	// 请求处理的总耗时
+	TotalLatency time.Duration
+	// 请求处理的最大耗时
+	MaxLatency time.Duration
+}
+
+// AvgLatency 请求处理的平均耗时
+func (s RouteStat) AvgLatency() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+
+	return s.TotalLatency / time.Duration(s.Count)
+}
+
+// routeCounter 路由的请求计数器
+type routeCounter struct {
+	count        atomic.Uint64
+	aborted      atomic.Uint64
+	totalLatency atomic.Int64
+	maxLatency   atomic.Int64
+}
+
+// Metrics 请求统计，按照消息ID统计请求数量与处理耗时
+type Metrics struct {
+	counters *core.KVShards[uint32, *routeCounter]
+	lock     sync.Mutex
+}
+
+// NewMetrics 创建请求统计
+func NewMetrics() *Metrics {
+	return &Metrics{
+		counters: core.NewKVShards[uint32, *routeCounter](16),
+	}
+}
+
+// counter 获取消息ID对应的计数器，不存在时创建
+func (m *Metrics) counter(id uint32) *routeCounter {
+	if c, ok := m.counters.Get(id); ok {
+		return c
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if c, ok := m.counters.Get(id); ok {
+		return c
+	}
+
+	c := &routeCounter{}
+	m.counters.Set(id, c)
+
+	return c
+}
+
+// Observe 记录一次请求
+func (m *Metrics) Observe(id uint32, latency time.Duration, aborted bool) {
+	c := m.counter(id)
+
+	c.count.Add(1)
+	if aborted {
+		c.aborted.Add(1)
+	}
+	c.totalLatency.Add(int64(latency))
+
+	for {
+		cur := c.maxLatency.Load()
+		if int64(latency) <= cur || c.maxLatency.CompareAndSwap(cur, int64(latency)) {
+			break
+		}
+	}
+}
+
+// Stat 获取消息ID对应的请求统计
+func (m *Metrics) Stat(id uint32) RouteStat {
+	c, ok := m.counters.Get(id)
+	if !ok {
+		return RouteStat{}
+	}
+
+	return RouteStat{
+		Count:        c.count.Load(),
+		Aborted:      c.aborted.Load(),
+		TotalLatency: time.Duration(c.totalLatency.Load()),
+		MaxLatency:   time.Duration(c.maxLatency.Load()),
+	}
+}
+
+// Stats 获取所有消息ID的请求统计
+func (m *Metrics) Stats() map[uint32]RouteStat {
+	stats := make(map[uint32]RouteStat)
+	for _, id := range m.counters.Keys() {
+		stats[id] = m.Stat(id)
+	}
+
+	return stats
+}
+
+// Measure 请求统计中间件，统计后续任务的请求数量与处理耗时
+func Measure[T any](metrics *Metrics) gcore.TaskFunc[T] {
+	return func(ctx trait.Context[T]) {
+		start := time.Now()
+
+		ctx.Next()
+
+		metrics.Observe(ctx.ID(), time.Since(start), ctx.IsAborted())
+	}
+}
@@ -0,0 +1,66 @@
+package gmiddleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zm50/gte/gcore"
+	"github.com/zm50/gte/trait"
+)
+
+func TestMeasure(t *testing.T) {
+	metrics := NewMetrics()
+	measure := Measure[int](metrics)
+
+	sleep := func(d time.Duration) gcore.TaskFunc[int] {
+		return func(ctx trait.Context[int]) {
+			time.Sleep(d)
+		}
+	}
+	abort := gcore.TaskFunc[int](func(ctx trait.Context[int]) {
+		ctx.Abort()
+	})
+
+	requests := []struct {
+		msgID   uint32
+		handler gcore.TaskFunc[int]
+	}{
+		{msgID: 1, handler: sleep(2 * time.Millisecond)},
+		{msgID: 1, handler: sleep(10 * time.Millisecond)},
+		{msgID: 1, handler: abort},
+		{msgID: 2, handler: sleep(0)},
+	}
+
+	for _, req := range requests {
+		run(newTestConn(1), req.msgID, measure, req.handler)
+	}
+
+	tests := []struct {
+		msgID       uint32
+		wantCount   uint64
+		wantAborted uint64
+		minMax      time.Duration
+	}{
+		{msgID: 1, wantCount: 3, wantAborted: 1, minMax: 10 * time.Millisecond},
+		{msgID: 2, wantCount: 1, wantAborted: 0},
+		{msgID: 3, wantCount: 0, wantAborted: 0},
+	}
+
+	for _, tt := range tests {
+		stat := metrics.Stat(tt.msgID)
+
+		if stat.Count != tt.wantCount || stat.Aborted != tt.wantAborted {
+			t.Fatalf("msg %d: count = %d aborted = %d, want %d %d", tt.msgID, stat.Count, stat.Aborted, tt.wantCount, tt.wantAborted)
+		}
+		if stat.MaxLatency < tt.minMax {
+			t.Fatalf("msg %d: max latency %s, want at least %s", tt.msgID, stat.MaxLatency, tt.minMax)
+		}
+		if stat.Count > 0 && stat.AvgLatency() != stat.TotalLatency/time.Duration(stat.Count) {
+			t.Fatalf("msg %d: avg latency %s does not match total %s", tt.msgID, stat.AvgLatency(), stat.TotalLatency)
+		}
+	}
+
+	if stats := metrics.Stats(); len(stats) != 2 {
+		t.Fatalf("stats has %d routes, want 2", len(stats))
+	}
+}
@@ -0,0 +1,100 @@
+package gmiddleware
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/zm50/gte/gcore"
+	"github.com/zm50/gte/glog"
+	"github.com/zm50/gte/gpack"
+	"github.com/zm50/gte/trait"
+	"k8s.io/klog/v2"
+)
+
+// logBuffer 测试期间保存glog输出的日志
+type logBuffer struct {
+	buf  bytes.Buffer
+	lock sync.Mutex
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.buf.String()
+}
+
+// logs 测试期间glog输出的日志
+var logs = &logBuffer{}
+
+func TestMain(m *testing.M) {
+	glog.Init()
+
+	// 日志写入内存，便于检查日志内容
+	klog.LogToStderr(false)
+	klog.SetOutput(logs)
+
+	os.Exit(m.Run())
+}
+
+// testConn 测试用的连接，只实现中间件使用的方法
+type testConn struct {
+	trait.Connection[int]
+
+	id            uint64
+	authenticated bool
+	ctx           context.Context
+	cancel        context.CancelFunc
+}
+
+func newTestConn(id uint64) *testConn {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &testConn{id: id, ctx: ctx, cancel: cancel}
+}
+
+func (c *testConn) ID() uint64 {
+	return c.id
+}
+
+func (c *testConn) Context() context.Context {
+	return c.ctx
+}
+
+func (c *testConn) IsAuthenticated() bool {
+	return c.authenticated
+}
+
+func (c *testConn) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: int(c.id)}
+}
+
+// run 在gcore.Context中执行中间件与处理函数，返回上下文与处理函数是否被执行
+func run(conn trait.Connection[int], msgID uint32, middleware gcore.TaskFunc[int], handlers ...gcore.TaskFunc[int]) (*gcore.Context[int], bool) {
+	handled := false
+
+	flow := []trait.TaskFunc[int]{middleware}
+	for _, handler := range handlers {
+		flow = append(flow, handler)
+	}
+	flow = append(flow, gcore.TaskFunc[int](func(ctx trait.Context[int]) {
+		handled = true
+	}))
+
+	ctx := gcore.NewContext(gcore.NewRequest(conn, gpack.NewMessage(msgID, []byte("data"))), gcore.NewTaskFlow(flow...), 0)
+	defer ctx.Release()
+
+	ctx.Run()
+
+	return ctx, handled
+}
@@ -0,0 +1,91 @@
+package gmiddleware
+
+import (
+	"context"
+	"sync"
+
+	"github.com/zm50/gte/core"
+	"github.com/zm50/gte/gcore"
+	"github.com/zm50/gte/trait"
+)
+
+// RateLimitConfig 限流中间件配置
+type RateLimitConfig[T any] struct {
+	// 每秒生成的令牌数量
+	Rate float64
+	// 令牌桶的容量，即允许的突发请求数量
+	Burst int
+	// 拒绝请求时的回调，为空时直接中止任务流
+	OnReject func(ctx trait.Context[T])
+}
+
+// ConnRateLimit 连接级别的限流中间件，每个连接持有一个令牌桶，连接关闭时释放
+func ConnRateLimit[T any](config RateLimitConfig[T]) gcore.TaskFunc[T] {
+	buckets := make(map[trait.Connection[T]]*core.TokenBucket)
+	lock := sync.Mutex{}
+
+	bucket := func(conn trait.Connection[T]) *core.TokenBucket {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if b, ok := buckets[conn]; ok {
+			return b
+		}
+
+		b := core.NewTokenBucket(config.Rate, config.Burst)
+		buckets[conn] = b
+
+		context.AfterFunc(conn.Context(), func() {
+			lock.Lock()
+			delete(buckets, conn)
+			lock.Unlock()
+		})
+
+		return b
+	}
+
+	return func(ctx trait.Context[T]) {
+		if !bucket(ctx.Conn()).Allow() {
+			reject(ctx, config.OnReject)
+		}
+	}
+}
+
+// RouteRateLimit 路由级别的限流中间件，每个消息ID持有一个令牌桶，所有连接共享
+func RouteRateLimit[T any](config RateLimitConfig[T]) gcore.TaskFunc[T] {
+	buckets := core.NewKVShards[uint32, *core.TokenBucket](16)
+	lock := sync.Mutex{}
+
+	bucket := func(id uint32) *core.TokenBucket {
+		if b, ok := buckets.Get(id); ok {
+			return b
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		if b, ok := buckets.Get(id); ok {
+			return b
+		}
+
+		b := core.NewTokenBucket(config.Rate, config.Burst)
+		buckets.Set(id, b)
+
+		return b
+	}
+
+	return func(ctx trait.Context[T]) {
+		if !bucket(ctx.ID()).Allow() {
+			reject(ctx, config.OnReject)
+		}
+	}
+}
+
+// reject 拒绝请求，中止任务流并执行拒绝回调
+func reject[T any](ctx trait.Context[T], onReject func(ctx trait.Context[T])) {
+	ctx.Abort()
+
+	if onReject != nil {
+		onReject(ctx)
+	}
+}
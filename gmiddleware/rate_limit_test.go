@@ -0,0 +1,99 @@
+package gmiddleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zm50/gte/gcore"
+	"github.com/zm50/gte/trait"
+)
+
+// request 测试中的一次请求与期望结果
+type request struct {
+	conn        *testConn
+	msgID       uint32
+	wantHandled bool
+	// 发送请求前等待的时间，用于令牌桶补充令牌
+	wait time.Duration
+}
+
+// runRequests 依次执行请求并检查是否被限流
+func runRequests(t *testing.T, limiter gcore.TaskFunc[int], rejects *int, requests []request) {
+	t.Helper()
+
+	wantRejects := 0
+	for i, req := range requests {
+		time.Sleep(req.wait)
+
+		ctx, handled := run(req.conn, req.msgID, limiter)
+		if handled != req.wantHandled {
+			t.Fatalf("request %d: handled = %t, want %t", i, handled, req.wantHandled)
+		}
+		if ctx.IsAborted() == req.wantHandled {
+			t.Fatalf("request %d: aborted = %t, want %t", i, ctx.IsAborted(), !req.wantHandled)
+		}
+
+		if !req.wantHandled {
+			wantRejects++
+		}
+	}
+
+	if *rejects != wantRejects {
+		t.Fatalf("rejects = %d, want %d", *rejects, wantRejects)
+	}
+}
+
+func TestConnRateLimit(t *testing.T) {
+	a, b := newTestConn(1), newTestConn(2)
+
+	rejects := 0
+	limiter := ConnRateLimit(RateLimitConfig[int]{
+		Rate:  100,
+		Burst: 2,
+		OnReject: func(ctx trait.Context[int]) {
+			rejects++
+		},
+	})
+
+	runRequests(t, limiter, &rejects, []request{
+		// 初始时令牌桶是满的，允许突发burst个请求
+		{conn: a, msgID: 1, wantHandled: true},
+		{conn: a, msgID: 2, wantHandled: true},
+		{conn: a, msgID: 3, wantHandled: false},
+		// 每个连接持有独立的令牌桶
+		{conn: b, msgID: 1, wantHandled: true},
+		{conn: b, msgID: 1, wantHandled: true},
+		{conn: b, msgID: 1, wantHandled: false},
+		// 等待足够长的时间后令牌补充到桶的容量，不会超过容量
+		{conn: a, msgID: 1, wantHandled: true, wait: 50 * time.Millisecond},
+		{conn: a, msgID: 1, wantHandled: true},
+		{conn: a, msgID: 1, wantHandled: false},
+	})
+}
+
+func TestRouteRateLimit(t *testing.T) {
+	a, b := newTestConn(1), newTestConn(2)
+
+	rejects := 0
+	limiter := RouteRateLimit(RateLimitConfig[int]{
+		Rate:  100,
+		Burst: 2,
+		OnReject: func(ctx trait.Context[int]) {
+			rejects++
+		},
+	})
+
+	runRequests(t, limiter, &rejects, []request{
+		// 同一消息ID的所有连接共享令牌桶
+		{conn: a, msgID: 1, wantHandled: true},
+		{conn: b, msgID: 1, wantHandled: true},
+		{conn: a, msgID: 1, wantHandled: false},
+		{conn: b, msgID: 1, wantHandled: false},
+		// 每个消息ID持有独立的令牌桶
+		{conn: a, msgID: 2, wantHandled: true},
+		// 等待令牌补充
+		{conn: b, msgID: 1, wantHandled: true, wait: 50 * time.Millisecond},
+		{conn: a, msgID: 1, wantHandled: true},
+		{conn: b, msgID: 1, wantHandled: false},
+	})
+}
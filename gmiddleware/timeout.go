@@ -0,0 +1,36 @@
+package gmiddleware
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/zm50/gte/gcore"
+	"github.com/zm50/gte/trait"
+)
+
+// TimeoutConfig 超时中间件配置
+type TimeoutConfig[T any] struct {
+	// 后续任务的处理超时时间
+	Timeout time.Duration
+	// 处理超时时的回调，在后续任务执行结束后调用，例如回复超时消息
+	OnTimeout func(ctx trait.Context[T])
+}
+
+// Timeout 超时中间件，为后续任务的上下文设置截止时间，任务需要通过ctx.Context()感知超时并尽快返回
+func Timeout[T any](config TimeoutConfig[T]) gcore.TaskFunc[T] {
+	return func(ctx trait.Context[T]) {
+		parent := ctx.Context()
+
+		timeoutCtx, cancel := context.WithTimeout(parent, config.Timeout)
+		defer cancel()
+
+		ctx.SetContext(timeoutCtx)
+		ctx.Next()
+		ctx.SetContext(parent)
+
+		if errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) && config.OnTimeout != nil {
+			config.OnTimeout(ctx)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package gmiddleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/zm50/gte/gcore"
+	"github.com/zm50/gte/trait"
+)
+
+func TestTimeout(t *testing.T) {
+	tests := []struct {
+		name        string
+		work        time.Duration
+		wantTimeout bool
+	}{
+		{name: "in time", work: 0, wantTimeout: false},
+		{name: "timeout", work: time.Second, wantTimeout: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timedOut := false
+			timeout := Timeout(TimeoutConfig[int]{
+				Timeout: 20 * time.Millisecond,
+				OnTimeout: func(ctx trait.Context[int]) {
+					timedOut = true
+				},
+			})
+
+			var handlerErr error
+			handler := gcore.TaskFunc[int](func(ctx trait.Context[int]) {
+				// 后续任务通过上下文感知超时
+				select {
+				case <-time.After(tt.work):
+				case <-ctx.Context().Done():
+				}
+				handlerErr = ctx.Context().Err()
+			})
+
+			ctx, handled := run(newTestConn(1), 1, timeout, handler)
+			if !handled {
+				t.Fatal("handler was not executed")
+			}
+			if timedOut != tt.wantTimeout {
+				t.Fatalf("timed out = %t, want %t", timedOut, tt.wantTimeout)
+			}
+			if tt.wantTimeout && handlerErr != context.DeadlineExceeded {
+				t.Fatalf("handler context err = %v, want deadline exceeded", handlerErr)
+			}
+
+			// 中间件返回后恢复原有的上下文
+			if _, ok := ctx.Context().Deadline(); ok {
+				t.Fatal("request context still has the middleware deadline")
+			}
+		})
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"io"
 
 	"github.com/pkg/errors"
+	"github.com/zm50/gte/gconf"
+	"github.com/zm50/gte/gtrace"
 	"github.com/zm50/gte/trait"
 )
 
@@ -13,11 +15,45 @@ import (
 直接面向TCP连接中的数据流，用于处理TCP粘包
 */
 
-// data开头的4字节是数据的长度,接下来的4字节是数据的id,在接下来是数据的具体内容
+// data开头的4字节是数据的长度,接下来的4字节是数据的id,开启链路上下文时接下来的24字节是链路上下文,在接下来是数据的具体内容
+// Websocket消息自带长度,开头的4字节是数据的id,开启链路上下文时接下来的24字节是链路上下文,在接下来是数据的具体内容
+
+// TraceContextLen 帧头部中链路上下文的长度，依次为16字节的链路ID与8字节的跨度ID
+const TraceContextLen = 24
+
+// traceContextLen 当前配置下帧头部中链路上下文的长度
+func traceContextLen() int {
+	if gconf.Config.TraceHeader() {
+		return TraceContextLen
+	}
+
+	return 0
+}
+
+// TCPHeaderLen 当前配置下TCP帧头部的长度
+func TCPHeaderLen() int {
+	return 8 + traceContextLen()
+}
+
+// putTraceContext 将链路上下文写入帧头部
+func putTraceContext(data []byte, sc gtrace.SpanContext) {
+	copy(data[:16], sc.TraceID[:])
+	copy(data[16:TraceContextLen], sc.SpanID[:])
+}
+
+// readTraceContext 从帧头部读取链路上下文
+func readTraceContext(data []byte) gtrace.SpanContext {
+	var sc gtrace.SpanContext
+	copy(sc.TraceID[:], data[:16])
+	copy(sc.SpanID[:], data[16:TraceContextLen])
+
+	return sc
+}
 
 // PackTCP 将Message封包成TCP数据流
 func PackTCP(msg trait.Message) []byte {
-	data := make([]byte, msg.DataLen()+8)
+	headerLen := TCPHeaderLen()
+	data := make([]byte, int(msg.DataLen())+headerLen)
 
 	//1.将datalen写到res中
 	binary.LittleEndian.PutUint32(data[:4], msg.DataLen())
@@ -25,28 +61,39 @@ func PackTCP(msg trait.Message) []byte {
 	//2.将message的id写入res中
 	binary.LittleEndian.PutUint32(data[4:8], msg.ID())
 
-	//3.将message的内容写到res中
-	copy(data[8:], msg.Data()[:msg.DataLen()])
+	//3.开启链路上下文时将链路上下文写入res中
+	if headerLen > 8 {
+		putTraceContext(data[8:headerLen], msg.TraceContext())
+	}
+
+	//4.将message的内容写到res中
+	copy(data[headerLen:], msg.Data()[:msg.DataLen()])
 
 	return data
 }
 
 // PackWebsocket 将Message封包成Websocket数据流
 func PackWebsocket(msg trait.Message) []byte {
-	data := make([]byte, msg.DataLen()+4)
+	headerLen := 4 + traceContextLen()
+	data := make([]byte, int(msg.DataLen())+headerLen)
 
 	//1.将message的id写入res中
 	binary.LittleEndian.PutUint32(data[:4], msg.ID())
 
-	//2.将message的内容写到res中
-	copy(data[4:], msg.Data()[:msg.DataLen()])
+	//2.开启链路上下文时将链路上下文写入res中
+	if headerLen > 4 {
+		putTraceContext(data[4:headerLen], msg.TraceContext())
+	}
+
+	//3.将message的内容写到res中
+	copy(data[headerLen:], msg.Data()[:msg.DataLen()])
 
 	return data
 }
 
 // UnpackTCPHeader 从TCP连接中读取数据，解包成Message
 func UnpackTCPHeader[T any](conn trait.Connection[T]) ([]byte, error) {
-	header := make([]byte, 8)
+	header := make([]byte, TCPHeaderLen())
 	_, err := io.ReadFull(conn, header)
 	if err != nil {
 		return nil, err
@@ -68,19 +115,28 @@ func UnpackTCPBody(reader io.Reader, header []byte) (trait.Message, error) {
 
 	msg := NewMessage(id, data)
 
+	if len(header) >= 8+TraceContextLen {
+		msg.SetTraceContext(readTraceContext(header[8:]))
+	}
+
 	return msg, nil	
 }
 
 // UnpackWebsocket 基于websocket读取的数据，解包成Message
 func UnpackWebsocket(data []byte) (trait.Message, error) {
-	if len(data) < 4 {
+	headerLen := 4 + traceContextLen()
+	if len(data) < headerLen {
 		return nil, errors.New("data too short")
 	}
 
 	// id (4 bytes)
-	id := binary.LittleEndian.Uint32(data[4:8])
+	id := binary.LittleEndian.Uint32(data[:4])
 
-	msg := NewMessage(id, data[4:])
+	msg := NewMessage(id, data[headerLen:])
+
+	if headerLen > 4 {
+		msg.SetTraceContext(readTraceContext(data[4:headerLen]))
+	}
 
 	return msg, nil
 }
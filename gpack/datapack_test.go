@@ -0,0 +1,63 @@
+package gpack
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/zm50/gte/gconf"
+	"github.com/zm50/gte/gtrace"
+)
+
+func TestPackRoundTrip(t *testing.T) {
+	sc := gtrace.SpanContext{
+		TraceID: gtrace.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		SpanID:  gtrace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+	}
+
+	tests := []struct {
+		name        string
+		traceHeader bool
+		id          uint32
+		data        []byte
+	}{
+		{name: "empty data", id: 1},
+		{name: "data", id: 0x01020304, data: []byte("hello")},
+		{name: "trace header", traceHeader: true, id: 0x01020304, data: []byte("hello")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gconf.Config.WithTraceHeader(tt.traceHeader)
+			defer gconf.Config.WithTraceHeader(false)
+
+			msg := NewMessage(tt.id, tt.data)
+			msg.SetTraceContext(sc)
+
+			check := func(kind string, got *Message) {
+				t.Helper()
+				if got.ID() != tt.id || !bytes.Equal(got.Data(), tt.data) {
+					t.Fatalf("%s unpack id %#x data %q, want id %#x data %q", kind, got.ID(), got.Data(), tt.id, tt.data)
+				}
+				if tt.traceHeader && got.TraceContext() != sc {
+					t.Fatalf("%s unpack trace context %v, want %v", kind, got.TraceContext(), sc)
+				}
+			}
+
+			r := bytes.NewReader(PackTCP(msg))
+			header := make([]byte, TCPHeaderLen())
+			r.Read(header)
+			tcpMsg, err := UnpackTCPBody(r, header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			check("tcp", tcpMsg.(*Message))
+
+			// Websocket消息开头的4字节是数据的id
+			wsMsg, err := UnpackWebsocket(PackWebsocket(msg))
+			if err != nil {
+				t.Fatal(err)
+			}
+			check("websocket", wsMsg.(*Message))
+		})
+	}
+}
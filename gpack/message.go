@@ -1,6 +1,7 @@
 package gpack
 
 import (
+	"github.com/zm50/gte/gtrace"
 	"github.com/zm50/gte/trait"
 )
 
@@ -12,6 +13,8 @@ type Message struct {
 	dataLen uint32
 	//消息的内容
 	data []byte
+	//消息携带的链路上下文
+	traceContext gtrace.SpanContext
 }
 
 var _ trait.Message = (*Message)(nil)
@@ -54,3 +57,13 @@ func (m *Message) SetDataLen(dataLen uint32) {
 func (m *Message) SetData(data []byte) {
 	m.data = data
 }
+
+// TraceContext 返回消息携带的链路上下文
+func (m *Message) TraceContext() gtrace.SpanContext {
+	return m.traceContext
+}
+
+// SetTraceContext 设置消息携带的链路上下文
+func (m *Message) SetTraceContext(traceContext gtrace.SpanContext) {
+	m.traceContext = traceContext
+}
@@ -0,0 +1,117 @@
+package gtrace
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Exporter 跨度导出器，跨度结束时在结束跨度的协程中同步调用，实现不能阻塞，导出失败的错误交给追踪器的错误处理函数
+type Exporter interface {
+	Export(span *Span) error
+}
+
+// MemoryExporter 将跨度保存在内存中，用于测试与调试
+type MemoryExporter struct {
+	spans []*Span
+	lock  sync.Mutex
+}
+
+var _ Exporter = (*MemoryExporter)(nil)
+
+// NewMemoryExporter 创建内存导出器
+func NewMemoryExporter() *MemoryExporter {
+	return &MemoryExporter{}
+}
+
+// Export 保存跨度
+func (e *MemoryExporter) Export(span *Span) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.spans = append(e.spans, span)
+
+	return nil
+}
+
+// Spans 获取已保存的跨度，按照结束的顺序排列
+func (e *MemoryExporter) Spans() []*Span {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	spans := make([]*Span, len(e.spans))
+	copy(spans, e.spans)
+
+	return spans
+}
+
+// Reset 清空已保存的跨度
+func (e *MemoryExporter) Reset() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.spans = nil
+}
+
+// WriterExporter 将跨度以JSON格式逐行写入io.Writer
+type WriterExporter struct {
+	w    io.Writer
+	lock sync.Mutex
+}
+
+var _ Exporter = (*WriterExporter)(nil)
+
+// NewWriterExporter 创建写入w的导出器
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{w: w}
+}
+
+// NewStdoutExporter 创建写入标准输出的导出器
+func NewStdoutExporter() *WriterExporter {
+	return NewWriterExporter(os.Stdout)
+}
+
+// spanRecord 跨度的JSON格式
+type spanRecord struct {
+	Name       string         `json:"name"`
+	TraceID    string         `json:"trace_id"`
+	SpanID     string         `json:"span_id"`
+	ParentID   string         `json:"parent_id,omitempty"`
+	StartTime  time.Time      `json:"start_time"`
+	DurationUs int64          `json:"duration_us"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// Export 写入一行跨度的JSON，返回序列化或写入的错误
+func (e *WriterExporter) Export(span *Span) error {
+	record := spanRecord{
+		Name:       span.Name,
+		TraceID:    span.TraceID.String(),
+		SpanID:     span.SpanID.String(),
+		StartTime:  span.StartTime,
+		DurationUs: span.Duration().Microseconds(),
+		Attributes: span.Attributes(),
+	}
+	if span.ParentID.IsValid() {
+		record.ParentID = span.ParentID.String()
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return errors.WithMessage(err, "marshal span failed")
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	_, err = e.w.Write(append(data, '\n'))
+	if err != nil {
+		return errors.WithMessage(err, "write span failed")
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package gtrace
+
+import (
+	"context"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceID 链路ID，同一条链路中的所有跨度共享
+type TraceID [16]byte
+
+// IsValid 链路ID是否有效，全零时无效
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+// String 十六进制格式的链路ID
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// SpanID 跨度ID
+type SpanID [8]byte
+
+// IsValid 跨度ID是否有效，全零时无效
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// String 十六进制格式的跨度ID
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// SpanContext 跨度的上下文，用于在进程之间传递链路
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+}
+
+// IsValid 链路ID与跨度ID是否都有效
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Span 跨度，记录链路中一个阶段的名称、起止时间与属性，所有方法对nil安全，未开启追踪时跨度为nil
+type Span struct {
+	tracer *Tracer
+
+	Name string
+	SpanContext
+	// 父跨度ID，根跨度的父跨度为客户端传递的跨度或为空
+	ParentID  SpanID
+	StartTime time.Time
+	EndTime   time.Time
+
+	attributes map[string]any
+	lock       sync.Mutex
+	ended      atomic.Bool
+}
+
+// Context 获取跨度的上下文
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+
+	return s.SpanContext
+}
+
+// SetAttribute 设置跨度的属性
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.attributes == nil {
+		s.attributes = make(map[string]any)
+	}
+	s.attributes[key] = value
+}
+
+// Attributes 获取跨度属性的副本
+func (s *Span) Attributes() map[string]any {
+	if s == nil {
+		return nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	attributes := make(map[string]any, len(s.attributes))
+	for key, value := range s.attributes {
+		attributes[key] = value
+	}
+
+	return attributes
+}
+
+// StartChild 从当前时间开始一个子跨度
+func (s *Span) StartChild(name string) *Span {
+	return s.StartChildAt(name, time.Now())
+}
+
+// StartChildAt 从指定时间开始一个子跨度
+func (s *Span) StartChildAt(name string, start time.Time) *Span {
+	if s == nil {
+		return nil
+	}
+
+	return s.tracer.StartAt(name, s.SpanContext, start)
+}
+
+// End 结束跨度并交给导出器导出，重复调用时只导出一次
+func (s *Span) End() {
+	s.EndAt(time.Now())
+}
+
+// EndAt 以指定的时间结束跨度
+func (s *Span) EndAt(end time.Time) {
+	if s == nil || !s.ended.CompareAndSwap(false, true) {
+		return
+	}
+
+	s.EndTime = end
+	s.tracer.export(s)
+}
+
+// Duration 跨度的耗时
+func (s *Span) Duration() time.Duration {
+	if s == nil {
+		return 0
+	}
+
+	return s.EndTime.Sub(s.StartTime)
+}
+
+type spanKey struct{}
+
+// ContextWithSpan 将跨度保存到标准库上下文中
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext 获取标准库上下文中的跨度，不存在时返回nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
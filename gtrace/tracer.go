@@ -0,0 +1,100 @@
+package gtrace
+
+import (
+	"encoding/binary"
+	"log"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+// Tracer 链路追踪器，创建跨度并在跨度结束时交给导出器，追踪器为nil时不创建跨度
+type Tracer struct {
+	exporter Exporter
+	// 根跨度的采样率，取值范围为0到1
+	sampleRate float64
+	// 导出跨度失败时调用的函数，为空时输出到标准日志
+	errorHandler atomic.Pointer[func(err error)]
+}
+
+// NewTracer 创建链路追踪器，sampleRate为没有父跨度的根跨度的采样率，携带父跨度的请求总是被追踪
+func NewTracer(exporter Exporter, sampleRate float64) *Tracer {
+	return &Tracer{
+		exporter:   exporter,
+		sampleRate: min(max(sampleRate, 0), 1),
+	}
+}
+
+// OnError 设置导出跨度失败时调用的函数，在结束跨度的协程中同步调用
+func (t *Tracer) OnError(fn func(err error)) {
+	t.errorHandler.Store(&fn)
+}
+
+// export 导出结束的跨度，导出失败时调用错误处理函数
+func (t *Tracer) export(span *Span) {
+	err := t.exporter.Export(span)
+	if err == nil {
+		return
+	}
+
+	if handler := t.errorHandler.Load(); handler != nil && *handler != nil {
+		(*handler)(err)
+		return
+	}
+
+	log.Printf("export span %s err: %v\n", span.Name, err)
+}
+
+// Start 从当前时间开始一个跨度，parent无效时创建新的链路
+func (t *Tracer) Start(name string, parent SpanContext) *Span {
+	return t.StartAt(name, parent, time.Now())
+}
+
+// StartAt 从指定时间开始一个跨度，parent无效时按照采样率创建新的链路，未被采样时返回nil
+func (t *Tracer) StartAt(name string, parent SpanContext, start time.Time) *Span {
+	if t == nil {
+		return nil
+	}
+
+	span := &Span{
+		tracer:    t,
+		Name:      name,
+		StartTime: start,
+	}
+
+	if parent.IsValid() {
+		span.TraceID = parent.TraceID
+		span.ParentID = parent.SpanID
+	} else {
+		if t.sampleRate < 1 && rand.Float64() >= t.sampleRate {
+			return nil
+		}
+
+		span.TraceID = newTraceID()
+	}
+
+	span.SpanID = newSpanID()
+
+	return span
+}
+
+// newTraceID 生成随机的链路ID
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(id[8:], rand.Uint64())
+	}
+
+	return id
+}
+
+// newSpanID 生成随机的跨度ID
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		binary.BigEndian.PutUint64(id[:], rand.Uint64())
+	}
+
+	return id
+}
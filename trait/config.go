@@ -11,21 +11,83 @@ type ServerConfig interface {
 	WriteInternal() int
 	NetworkMode() int
 	MaxConns() int32
- 	MaxPacketSize() int
+	MaxConnsPerIP() int
+	CIDRConnLimits() map[string]int
+	AcceptRate() int
+	AcceptBurst() int
+	AllowList() []string
+	DenyList() []string
+	RejectRetryAfter() int
+	ProxyProtocol() bool
+	ProxyHeaderTimeout() int
+	MaxProxyHeaderReads() int
+	TrustedProxies() []string
+	RealIPHeaders() []string
+	TCPNoDelay() bool
+	TCPKeepAlive() bool
+	TCPKeepAliveIdle() int
+	TCPKeepAliveInterval() int
+	TCPKeepAliveCount() int
+	TCPRecvBuffer() int
+	TCPSendBuffer() int
+	TCPLinger() int
+	TCPUserTimeout() int
+	TCPFastOpen() int
+	TCPDeferAccept() int
+	AdminAddr() string
+	MetricsPath() string
+	AdminToken() string
+	AdminPprof() bool
+	TraceHeader() bool
+	SlowHandlerThreshold() int
+	SlowQueueThreshold() int
+	ObserverAsync() bool
+	ObserverQueueLen() int
+	ObserverWorkers() int
+	MaxPacketSize() int
 	EpollTimeout() int
 	EpollEventSize() int
 	DispatcherQueues() int
 	DispatcherQueueLen() int
 	WorkersPerDispatcherQueue() int
+	MinWorkersPerDispatcherQueue() int
+	MaxWorkersPerDispatcherQueue() int
 	TaskQueues() int
 	TaskQueueLen() int
 	WorkersPerTaskQueue() int
+	TaskSchedulePolicy() int
+	TaskPriorityWeights() []int
+	MinWorkersPerTaskQueue() int
+	MaxWorkersPerTaskQueue() int
+	OverloadPolicy() int
+	OverloadMsgID() uint32
+	HeartbeatMsgID() uint32
+	WorkerAutoScale() bool
+	WorkerScaleInterval() int
+	WorkerScaleUpQueueLen() int
+	WorkerScaleUpWaitTime() int
+	WorkerScaleDownIdleRounds() int
 	WebsocketQueueLen() int
+	WebsocketPaths() []string
+	WebsocketOrigins() []string
+	WebsocketSubprotocols() []string
+	WebsocketCompression() bool
+	WebsocketCompressionLevel() int
+	WebsocketPingInterval() int
 	ConnSignalQueues() int
 	ConnSignalQueueLen() int
 	WorkersPerConnSignalQueue() int
 	ConnShardCount() int
 	HealthCheckInterval() int
+	IdlePolicy() int
+	IdleMaxMissed() int
+	ReadIdleTimeout() int
+	WriteIdleTimeout() int
+	AllIdleTimeout() int
+	TimingWheelTick() int
+	TimingWheelSize() int
+	HandshakeMsgIDs() []uint32
+	HandshakeTimeout() int
 	LogFilename() string
 	LogMaxSize() int
 	LogMaxBackups() int
@@ -39,21 +101,83 @@ type ServerConfig interface {
 	WithWriteInternal(int) ServerConfig
 	WithNetworkMode(int) ServerConfig
 	WithMaxConns(int32) ServerConfig
- 	WithMaxPacketSize(int) ServerConfig
+	WithMaxConnsPerIP(int) ServerConfig
+	WithCIDRConnLimits(map[string]int) ServerConfig
+	WithAcceptRate(int) ServerConfig
+	WithAcceptBurst(int) ServerConfig
+	WithAllowList([]string) ServerConfig
+	WithDenyList([]string) ServerConfig
+	WithRejectRetryAfter(int) ServerConfig
+	WithProxyProtocol(bool) ServerConfig
+	WithProxyHeaderTimeout(int) ServerConfig
+	WithMaxProxyHeaderReads(int) ServerConfig
+	WithTrustedProxies([]string) ServerConfig
+	WithRealIPHeaders([]string) ServerConfig
+	WithTCPNoDelay(bool) ServerConfig
+	WithTCPKeepAlive(bool) ServerConfig
+	WithTCPKeepAliveIdle(int) ServerConfig
+	WithTCPKeepAliveInterval(int) ServerConfig
+	WithTCPKeepAliveCount(int) ServerConfig
+	WithTCPRecvBuffer(int) ServerConfig
+	WithTCPSendBuffer(int) ServerConfig
+	WithTCPLinger(int) ServerConfig
+	WithTCPUserTimeout(int) ServerConfig
+	WithTCPFastOpen(int) ServerConfig
+	WithTCPDeferAccept(int) ServerConfig
+	WithAdminAddr(string) ServerConfig
+	WithMetricsPath(string) ServerConfig
+	WithAdminToken(string) ServerConfig
+	WithAdminPprof(bool) ServerConfig
+	WithTraceHeader(bool) ServerConfig
+	WithSlowHandlerThreshold(int) ServerConfig
+	WithSlowQueueThreshold(int) ServerConfig
+	WithObserverAsync(bool) ServerConfig
+	WithObserverQueueLen(int) ServerConfig
+	WithObserverWorkers(int) ServerConfig
+	WithMaxPacketSize(int) ServerConfig
 	WithEpollTimeout(int) ServerConfig
 	WithEpollEventSize(int) ServerConfig
 	WithDispatcherQueues(int) ServerConfig
 	WithDispatcherQueueLen(int) ServerConfig
 	WithWorkersPerDispatcherQueue(int) ServerConfig
+	WithMinWorkersPerDispatcherQueue(int) ServerConfig
+	WithMaxWorkersPerDispatcherQueue(int) ServerConfig
 	WithTaskQueues(int) ServerConfig
 	WithTaskQueueLen(int) ServerConfig
 	WithWorkersPerTaskQueue(int) ServerConfig
+	WithTaskSchedulePolicy(int) ServerConfig
+	WithTaskPriorityWeights([]int) ServerConfig
+	WithMinWorkersPerTaskQueue(int) ServerConfig
+	WithMaxWorkersPerTaskQueue(int) ServerConfig
+	WithOverloadPolicy(int) ServerConfig
+	WithOverloadMsgID(uint32) ServerConfig
+	WithHeartbeatMsgID(uint32) ServerConfig
+	WithWorkerAutoScale(bool) ServerConfig
+	WithWorkerScaleInterval(int) ServerConfig
+	WithWorkerScaleUpQueueLen(int) ServerConfig
+	WithWorkerScaleUpWaitTime(int) ServerConfig
+	WithWorkerScaleDownIdleRounds(int) ServerConfig
 	WithWebsocketQueueLen(int) ServerConfig
+	WithWebsocketPaths([]string) ServerConfig
+	WithWebsocketOrigins([]string) ServerConfig
+	WithWebsocketSubprotocols([]string) ServerConfig
+	WithWebsocketCompression(bool) ServerConfig
+	WithWebsocketCompressionLevel(int) ServerConfig
+	WithWebsocketPingInterval(int) ServerConfig
 	WithConnSignalQueues(int) ServerConfig
 	WithConnSignalQueueLen(int) ServerConfig
 	WithWorkersPerConnSignalQueue(int) ServerConfig
 	WithConnShardCount(connShardCount int) ServerConfig
 	WithHealthCheckInterval(int) ServerConfig
+	WithIdlePolicy(int) ServerConfig
+	WithIdleMaxMissed(int) ServerConfig
+	WithReadIdleTimeout(int) ServerConfig
+	WithWriteIdleTimeout(int) ServerConfig
+	WithAllIdleTimeout(int) ServerConfig
+	WithTimingWheelTick(int) ServerConfig
+	WithTimingWheelSize(int) ServerConfig
+	WithHandshakeMsgIDs([]uint32) ServerConfig
+	WithHandshakeTimeout(int) ServerConfig
 	WithLogFilename(string) ServerConfig
 	WithLogMaxSize(int) ServerConfig
 	WithLogMaxBackups(int) ServerConfig
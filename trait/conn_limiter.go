@@ -0,0 +1,13 @@
+package trait
+
+import "net"
+
+type ConnLimiter interface {
+	AllowAccept() bool
+	Acquire(ip net.IP) (bool, int)
+	Release(ip net.IP)
+	Reject(reason int)
+	RejectCount(reason int) uint64
+	SetAllowList(list []string) error
+	SetDenyList(list []string) error
+}
@@ -1,13 +1,19 @@
 package trait
 
 import (
+	"context"
 	"sync"
+
+	"github.com/zm50/gte/core"
 )
 
 type ConnMgr[T any] interface {
 	Get(fd int32) (Connection[T], bool)
 	Add(conn Connection[T]) error
 	Del(fd int32) error
+	Close(fd int32, reason int) error
+	CloseConn(conn Connection[T], reason int) error
+	Conns() []Connection[T]
 	Wait() (int, error)
 	BatchCommit(n int)
 	Start()
@@ -17,8 +23,18 @@ type ConnMgr[T any] interface {
 	OnConnStart(func(conn Connection[T]))
 	OnConnStop(func(conn Connection[T]))
 	OnConnNotActive(fn func(conn Connection[T]))
+	OnConnIdle(fn func(conn Connection[T], idleState int))
 	ChooseConnSignalQueue(connID uint64) chan <- ConnSignal[T]
 	PushConnSignal(signal ConnSignal[T])
+	PendingConnSignals() int
 	WaitGroup() *sync.WaitGroup
 	OnlineConns() int32
+	Context() context.Context
+	Dispatcher() Dispatcher[T]
+	Observer() Observer[T]
+	TimingWheel() *core.TimingWheel
+	Pause(conn Connection[T]) error
+	Resume(conn Connection[T]) error
+	Overload(request Request[T]) (bool, error)
+	OverloadCount(policy int) uint64
 }
@@ -1,8 +1,12 @@
 package trait
 
 import (
+	"context"
 	"net"
 	"os"
+	"time"
+
+	"github.com/zm50/gte/core"
 )
 
 type Socket interface {
@@ -17,6 +21,7 @@ type Connection[T any] interface {
 	ID() uint64
 	Send(data []byte) error
 	SendMsg(msgID uint32, data []byte) error
+	SendMessage(msg Message) error
 	Stop()
 	BatchCommit() error
 	IsAlive() bool
@@ -24,7 +29,21 @@ type Connection[T any] interface {
 	IsClose() bool
 	State() uint32
 	SetState(state uint32)
+	LastReadTime() time.Time
+	LastWriteTime() time.Time
+	StartTime() time.Time
+	BytesIn() uint64
+	BytesOut() uint64
+	Probe() error
+	AfterFunc(d time.Duration, fn func()) *core.Timer
+	Every(d time.Duration, fn func()) *core.Timer
 
 	Property() T
 	SetProperty(T)
+
+	Authenticate(identity any)
+	IsAuthenticated() bool
+	Identity() any
+
+	Context() context.Context
 }
@@ -1,8 +1,19 @@
 package trait
 
+import "context"
+
 type Context[T any] interface {
 	Request[T]
 
 	Next()
 	Abort()
+	IsAborted() bool
+	AbortWithMsg(msgID uint32, data []byte) error
+	SendMsg(msgID uint32, data []byte) error
+	Defer(fn func())
+
+	Context() context.Context
+	SetContext(ctx context.Context)
+	Set(key string, value any)
+	Get(key string) (any, bool)
 }
@@ -6,9 +6,11 @@ import (
 
 type Dispatcher[T any] interface {
 	Start()
-	Dispatch(connQueue chan Connection[T])
+	Dispatch(queueID int)
 	SetHeaderDeadline(deadline time.Time)
 	SetBodyDeadline(deadline time.Time)
 	ChooseQueue(connID uint64) chan <- Connection[T]
 	Commit(conn Connection[T])
+	Workers() int
+	PendingConns() int
 }
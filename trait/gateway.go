@@ -1,6 +1,15 @@
 package trait
 
+import "net/http"
+
 type Gateway[T any] interface {
 	ListenAndServe() error
 	Accept() (Connection[T], error)
+	Stop() error
+}
+
+// WebsocketGateway Websocket网关，支持在升级连接前回调钩子函数
+type WebsocketGateway[T any] interface {
+	Gateway[T]
+	OnUpgrade(fn func(r *http.Request) (T, error))
 }
@@ -1,11 +1,15 @@
 package trait
 
+import "github.com/zm50/gte/gtrace"
+
 type Message interface {
 	ID() uint32
 	DataLen() uint32
 	Data() []byte
+	TraceContext() gtrace.SpanContext
 
 	SetID(uint32)
 	SetDataLen(uint32)
 	SetData([]byte)
+	SetTraceContext(gtrace.SpanContext)
 }
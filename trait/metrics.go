@@ -0,0 +1,31 @@
+package trait
+
+import "io"
+
+// Counter 只增不减的计数器，labelValues与注册时的标签名按顺序对应
+type Counter interface {
+	Add(delta float64, labelValues ...string)
+}
+
+// Gauge 可以任意设置的仪表盘
+type Gauge interface {
+	Set(value float64, labelValues ...string)
+	Add(delta float64, labelValues ...string)
+}
+
+// Histogram 直方图，按照桶的上界统计观测值的分布
+type Histogram interface {
+	Observe(value float64, labelValues ...string)
+}
+
+// MetricsRegistry 指标注册表抽象层，可以替换为自定义的实现以接入其他监控系统
+type MetricsRegistry interface {
+	// 同名指标重复注册时返回已注册的指标
+	Counter(name string, help string, labelNames ...string) Counter
+	Gauge(name string, help string, labelNames ...string) Gauge
+	Histogram(name string, help string, buckets []float64, labelNames ...string) Histogram
+	// OnCollect 注册采集指标前执行的回调，用于更新队列深度等瞬时值
+	OnCollect(fn func())
+	// WriteTo 以Prometheus文本格式输出所有指标
+	io.WriterTo
+}
@@ -0,0 +1,17 @@
+package trait
+
+import "net"
+
+type Observer[T any] interface {
+	Start()
+	Stop()
+	OnMessageIn(fn func(conn Connection[T], msg Message))
+	OnMessageOut(fn func(conn Connection[T], msg Message))
+	OnConnError(fn func(conn Connection[T], err error))
+	OnAccept(fn func(conn net.Conn) bool)
+	MessageIn(conn Connection[T], msg Message)
+	MessageOut(conn Connection[T], msg Message)
+	ConnError(conn Connection[T], err error)
+	Accept(conn net.Conn) bool
+	Dropped(event int) uint64
+}
@@ -1,7 +1,15 @@
 package trait
 
+import (
+	"time"
+
+	"github.com/zm50/gte/gtrace"
+)
+
 type Request[T any] interface {
 	Message
 
 	Conn() Connection[T]
+	CommitTime() time.Time
+	Span() *gtrace.Span
 }
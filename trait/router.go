@@ -1,7 +1,37 @@
 package trait
 
+import "time"
+
+type Route[T any] interface {
+	ID() uint32
+	Flow() TaskFlow[T]
+	Timeout() time.Duration
+	Priority() int
+	Enabled() bool
+	Name() string
+	Desc() string
+	Group() string
+	Handlers() []string
+	SlowHandlerThreshold() time.Duration
+	SlowQueueThreshold() time.Duration
+
+	WithTimeout(timeout time.Duration) Route[T]
+	WithSlowHandlerThreshold(threshold time.Duration) Route[T]
+	WithSlowQueueThreshold(threshold time.Duration) Route[T]
+	WithPriority(priority int) Route[T]
+	WithName(name string) Route[T]
+	WithDesc(desc string) Route[T]
+	WithGroup(group string) Route[T]
+}
+
 type Router[T any] interface {
-	Regist(id uint32, flow ...TaskFunc[T])
-	RegistFlow(id uint32, flow TaskFlow[T])
+	Regist(id uint32, flow ...TaskFunc[T]) Route[T]
+	RegistFlow(id uint32, flow TaskFlow[T]) Route[T]
+	Replace(id uint32, flow ...TaskFunc[T]) Route[T]
+	Unregist(id uint32) bool
+	Enable(id uint32) bool
+	Disable(id uint32) bool
+	Route(id uint32) (Route[T], bool)
+	Routes() []Route[T]
 	TaskFlow(id uint32) TaskFlow[T]
 }
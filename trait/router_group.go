@@ -4,5 +4,7 @@ type RouterGroup[T any] interface {
 	Router[T]
 
 	Group(flow ...TaskFunc[T]) RouterGroup[T]
+	NamedGroup(name string, flow ...TaskFunc[T]) RouterGroup[T]
+	Path() string
 	Use(flow ...TaskFunc[T])
 }
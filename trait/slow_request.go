@@ -0,0 +1,12 @@
+package trait
+
+import "time"
+
+type SlowRequest[T any] interface {
+	Request[T]
+	Kind() int
+	Route() Route[T]
+	Elapsed() time.Duration
+	Threshold() time.Duration
+	Stack() string
+}
@@ -1,10 +1,22 @@
 package trait
 
+import (
+	"context"
+
+	"github.com/zm50/gte/gtrace"
+)
+
 type TaskMgr[T any] interface {
 	RouterGroup[T]
 
-	Start()
-	StartWorker(taskQueue <- chan Request[T])
-	ChooseQueue(connID uint64) chan <- Request[T]
+	Start(ctx context.Context)
+	StartWorker(queueID int)
+	ChooseQueue(connID uint64, priority int) chan<- Request[T]
 	Submit(request Request[T])
+	TrySubmit(request Request[T]) bool
+	PendingTasks(priority int) int
+	Workers() int
+	OnSlowRequest(fn func(event SlowRequest[T]))
+	SetTracer(tracer *gtrace.Tracer)
+	Tracer() *gtrace.Tracer
 }